@@ -0,0 +1,87 @@
+// +build !nomon
+
+package mon
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"unsafe"
+)
+
+// parentKey is the context key holding the name of the enclosing call.
+type parentKey struct{}
+
+// edgeKey returns the key in the edges table for a parent and child. The key
+// starts with the length of the parent so that it is unique no matter what the
+// names contain.
+func edgeKey(parent, child string) string {
+	return strconv.Itoa(len(parent)) + ":" + parent + child
+}
+
+// splitEdgeKey returns the parent and child of a key from edgeKey.
+func splitEdgeKey(key string) (parent, child string) {
+	i := strings.IndexByte(key, ':')
+	n, _ := strconv.Atoi(key[:i])
+	return key[i+1 : i+1+n], key[i+1+n:]
+}
+
+func newEdgeState(key string) unsafe.Pointer {
+	_, child := splitEdgeKey(key)
+	return unsafe.Pointer(newTimerState(child, nil))
+}
+
+// LookupEdge returns the current state for calls to child made while inside of
+// parent in the Default registry, returning nil if none exists.
+func LookupEdge(parent, child string) *State { return Default.LookupEdge(parent, child) }
+
+// Edges calls the callback with the state for every edge in the Default registry.
+// See Registry.Edges.
+func Edges(cb func(parent, child string, state *State) bool) { Default.Edges(cb) }
+
+// getEdge returns the current state for the edge, allocating a new one if
+// necessary. If there are too many edges, it returns the overflow edge instead.
+func (r *Registry) getEdge(parent, child string) *State {
+	token := r.tracker.Acquire()
+	gen := token.Gen()
+	key := edgeKey(parent, child)
+	var state *State
+	if r.admit(&r.edges[gen%2], &r.edgeNames[gen%2], key) {
+		state = (*State)(r.edges[gen%2].UpsertKey(key, newEdgeState))
+	} else {
+		key, state = edgeKey(OverflowName, OverflowName), r.overflowEdge(gen)
+	}
+	r.found(state, &r.shared[1], key)
+	token.Release()
+	return state
+}
+
+// LookupEdge returns the current state for calls to child made while inside of
+// parent, returning nil if none exists.
+func (r *Registry) LookupEdge(parent, child string) *State {
+	token := r.tracker.Acquire()
+	state := (*State)(r.edges[token.Gen()%2].Lookup(edgeKey(parent, child)))
+	token.Release()
+	return state
+}
+
+// Edges calls the callback with the state for every parent to child edge that
+// has been captured by timers started with a context. Like timers, the edges
+// are consumed by Collect.
+func (r *Registry) Edges(cb func(parent, child string, state *State) bool) {
+	token := r.tracker.Acquire()
+	for iter := r.edges[token.Gen()%2].Iterator(); iter.Next(); {
+		parent, child := splitEdgeKey(iter.Key())
+		if !cb(parent, child, (*State)(iter.Value())) {
+			goto done
+		}
+	}
+done:
+	token.Release()
+}
+
+// contextParent returns the name of the call the context was started in, if any.
+func contextParent(ctx context.Context) (string, bool) {
+	parent, ok := ctx.Value(parentKey{}).(string)
+	return parent, ok
+}
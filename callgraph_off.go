@@ -0,0 +1,17 @@
+// +build nomon
+
+package mon
+
+// LookupEdge returns the current state for calls to child made while inside of
+// parent in the Default registry.
+func LookupEdge(parent, child string) *State { return nil }
+
+// Edges calls the callback with the state for every edge in the Default registry.
+func Edges(cb func(parent, child string, state *State) bool) {}
+
+// LookupEdge returns the current state for calls to child made while inside of
+// parent.
+func (r *Registry) LookupEdge(parent, child string) *State { return nil }
+
+// Edges calls the callback with the state for every parent to child edge.
+func (r *Registry) Edges(cb func(parent, child string, state *State) bool) {}
@@ -0,0 +1,63 @@
+// +build !nomon
+
+package mon
+
+import (
+	"context"
+	"testing"
+
+	"github.com/zeebo/assert"
+)
+
+func TestCallGraph(t *testing.T) {
+	defer Collect(func(string, *State) bool { return true })
+
+	ctx, outer := StartNamedContext(context.Background(), "outer")
+	for i := 0; i < 3; i++ {
+		ctx, inner := StartNamedContext(ctx, "inner")
+		_, leaf := StartNamedContext(ctx, "leaf")
+		leaf.Stop(nil)
+		inner.Stop(nil)
+	}
+	_, leaf := StartNamedContext(ctx, "leaf")
+	leaf.Stop(nil)
+	outer.Stop(nil)
+
+	got := make(map[[2]string]int64)
+	Edges(func(parent, child string, st *State) bool {
+		assert.Equal(t, st.Name(), child)
+		got[[2]string{parent, child}] = st.Total()
+		return true
+	})
+	assert.DeepEqual(t, got, map[[2]string]int64{
+		{"outer", "inner"}: 3,
+		{"inner", "leaf"}:  3,
+		{"outer", "leaf"}:  1,
+	})
+
+	assert.Equal(t, LookupState("leaf").Total(), 4)
+	assert.Equal(t, LookupEdge("outer", "leaf").Total(), 1)
+	assert.Nil(t, LookupEdge("leaf", "outer"))
+}
+
+func TestCallGraphKeys(t *testing.T) {
+	var reg Registry
+	for _, names := range [][2]string{{"a\x00b", "c"}, {"a", "b\x00c"}, {"1:a", "b"}} {
+		ctx, parent := reg.StartNamedContext(context.Background(), names[0])
+		_, child := reg.StartNamedContext(ctx, names[1])
+		child.Stop(nil)
+		parent.Stop(nil)
+	}
+
+	got := make(map[[2]string]int64)
+	reg.Edges(func(parent, child string, st *State) bool {
+		assert.Equal(t, st.Name(), child)
+		got[[2]string{parent, child}] = st.Total()
+		return true
+	})
+	assert.DeepEqual(t, got, map[[2]string]int64{
+		{"a\x00b", "c"}: 1,
+		{"a", "b\x00c"}: 1,
+		{"1:a", "b"}:    1,
+	})
+}
@@ -0,0 +1,174 @@
+// +build !nomon
+
+package mon
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/zeebo/mon/internal/lfht"
+)
+
+// Classifier returns the error kind for an error, and false if it does not
+// recognize the error.
+type Classifier func(err error) (kind string, ok bool)
+
+var (
+	classifiersMu sync.Mutex   // protects concurrent RegisterClassifier calls.
+	classifiers   atomic.Value // []Classifier consulted before the builtins.
+)
+
+// RegisterClassifier adds a Classifier that Timer.Stop consults to determine
+// the kind of an error. Classifiers are consulted in the order they were
+// registered, and before the builtin ones, which in order
+//
+//   - use a Name() (string, bool) method on any error in the Unwrap chain
+//   - recognize well known standard library errors like context.Canceled
+//   - recognize *net.OpError and *net.DNSError
+//   - use the text of the error before the first colon
+func RegisterClassifier(c Classifier) {
+	classifiersMu.Lock()
+	cs, _ := classifiers.Load().([]Classifier)
+	classifiers.Store(append(cs[:len(cs):len(cs)], c))
+	classifiersMu.Unlock()
+}
+
+// builtinClassifiers are consulted after any registered classifiers.
+var builtinClassifiers = [...]Classifier{
+	classifyNamed,
+	classifySentinel,
+	classifyNet,
+}
+
+// getKind returns a string that attemps to be representative of the error.
+func getKind(err error) string {
+	if err == nil {
+		return ""
+	}
+
+	cs, _ := classifiers.Load().([]Classifier)
+	for _, c := range cs {
+		if kind, ok := c(err); ok {
+			return kind
+		}
+	}
+	for _, c := range builtinClassifiers {
+		if kind, ok := c(err); ok {
+			return kind
+		}
+	}
+
+	s := err.Error()
+	if i := strings.IndexByte(s, ':'); i > 0 {
+		return s[:i]
+	} else if strings.IndexByte(s, ' ') == -1 {
+		return s
+	} else {
+		return "error"
+	}
+}
+
+// classifyNamed uses the first Name method in the Unwrap chain of the error.
+func classifyNamed(err error) (string, bool) {
+	for ; err != nil; err = errors.Unwrap(err) {
+		if n, ok := err.(interface{ Name() (string, bool) }); ok {
+			if name, ok := n.Name(); ok {
+				return name, true
+			}
+		}
+	}
+	return "", false
+}
+
+// sentinels are well known errors that are used as their own kind.
+var sentinels = [...]error{
+	context.Canceled,
+	context.DeadlineExceeded,
+	io.EOF,
+	io.ErrUnexpectedEOF,
+	io.ErrClosedPipe,
+	os.ErrNotExist,
+	os.ErrExist,
+	os.ErrPermission,
+}
+
+// classifySentinel uses the text of any well known error the error wraps.
+func classifySentinel(err error) (string, bool) {
+	for _, sentinel := range sentinels {
+		if errors.Is(err, sentinel) {
+			return sentinel.Error(), true
+		}
+	}
+	return "", false
+}
+
+// classifyNet uses the operation of a network error the error wraps.
+func classifyNet(err error) (string, bool) {
+	var opErr *net.OpError
+	var dnsErr *net.DNSError
+	var kind string
+	if errors.As(err, &opErr) {
+		kind = "net " + opErr.Op
+	} else if errors.As(err, &dnsErr) {
+		kind = "net lookup"
+	} else {
+		return "", false
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		kind += " timeout"
+	}
+	return kind, true
+}
+
+//
+// error kind limits
+//
+
+// OverflowKind is the error kind used once a State has too many error kinds.
+const OverflowKind = "overflow"
+
+// SetErrorKindLimit sets the error kind limit of the Default registry. See
+// Registry.SetErrorKindLimit.
+func SetErrorKindLimit(n int) { Default.SetErrorKindLimit(n) }
+
+// SetErrorKindLimit sets an approximate limit on the number of distinct error
+// kinds each State in the registry keeps track of, and each minute of its
+// rolling windows. Once the limit is hit, errors of new kinds are counted
+// under the kind "overflow". A limit of zero, the default, means there is no
+// limit.
+func (r *Registry) SetErrorKindLimit(n int) { atomic.StoreInt64(&r.kindLimit, int64(n)) }
+
+// errorKindLimit returns the error kind limit of the registry of the state.
+func (s *State) errorKindLimit() int64 {
+	if r := s.registry(); r != nil {
+		return atomic.LoadInt64(&r.kindLimit)
+	}
+	return 0
+}
+
+// countError adds n to the counter for the error kind.
+func (s *State) countError(kind string, n int64) {
+	countKind(&s.timer.errors, &s.timer.kinds, s.errorKindLimit(), kind, n)
+}
+
+// countKind adds n to the counter for the error kind in the table, which has
+// about kinds error kinds, counting it under OverflowKind if it is new and
+// there are more than limit.
+func countKind(errors *lfht.Table, kinds *int64, limit int64, kind string, n int64) {
+	counter := (*int64)(errors.Lookup(kind))
+	if counter == nil {
+		if k := atomic.AddInt64(kinds, 1); limit > 0 && k > limit {
+			kind = OverflowKind
+		}
+		counter = (*int64)(errors.Upsert(kind, newCounter))
+	}
+	atomic.AddInt64(counter, n)
+}
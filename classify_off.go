@@ -0,0 +1,21 @@
+// +build nomon
+
+package mon
+
+// Classifier returns the error kind for an error, and false if it does not
+// recognize the error.
+type Classifier func(err error) (kind string, ok bool)
+
+// RegisterClassifier adds a Classifier that Timer.Stop consults to determine
+// the kind of an error.
+func RegisterClassifier(c Classifier) {}
+
+// OverflowKind is the error kind used once a State has too many error kinds.
+const OverflowKind = "overflow"
+
+// SetErrorKindLimit sets the error kind limit of the Default registry.
+func SetErrorKindLimit(n int) {}
+
+// SetErrorKindLimit sets an approximate limit on the number of distinct error
+// kinds each State in the registry keeps track of.
+func (r *Registry) SetErrorKindLimit(n int) {}
@@ -0,0 +1,105 @@
+// +build !nomon
+
+package mon
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/zeebo/assert"
+	"github.com/zeebo/errs"
+)
+
+type classifiedError struct{}
+
+func (classifiedError) Error() string { return "classified: whatever" }
+
+func TestClassify(t *testing.T) {
+	RegisterClassifier(func(err error) (string, bool) {
+		if errors.As(err, new(classifiedError)) {
+			return "custom", true
+		}
+		return "", false
+	})
+
+	class := errs.Class("database")
+	timeout := &net.OpError{Op: "dial", Err: &net.DNSError{IsTimeout: true}}
+
+	for _, tc := range []struct {
+		err  error
+		kind string
+	}{
+		{nil, ""},
+		{errors.New("problem"), "problem"},
+		{errors.New("some problem"), "error"},
+		{errors.New("prefix: some problem"), "prefix"},
+		{class.New("boom"), "database"},
+		{fmt.Errorf("query: %w", class.New("boom")), "database"},
+		{fmt.Errorf("call: %w", context.DeadlineExceeded), "context deadline exceeded"},
+		{context.Canceled, "context canceled"},
+		{&os.PathError{Op: "open", Path: "x", Err: os.ErrNotExist}, "file does not exist"},
+		{&net.OpError{Op: "read", Err: errors.New("reset")}, "net read"},
+		{fmt.Errorf("get: %w", timeout), "net dial timeout"},
+		{&net.DNSError{Name: "example"}, "net lookup"},
+		{fmt.Errorf("wrapped: %w", classifiedError{}), "custom"},
+	} {
+		assert.Equal(t, getKind(tc.err), tc.kind)
+	}
+}
+
+func TestErrorKindLimit(t *testing.T) {
+	SetErrorKindLimit(2)
+	defer SetErrorKindLimit(0)
+	defer Collect(func(string, *State) bool { return true })
+
+	for i := 0; i < 5; i++ {
+		err := fmt.Errorf("kind%d", i)
+		StartNamed("limited").Stop(&err)
+	}
+	err := errors.New("kind0")
+	StartNamed("limited").Stop(&err)
+
+	got := make(map[string]int64)
+	for iter := LookupState("limited").Errors().Iterator(); iter.Next(); {
+		got[iter.Key()] = *(*int64)(iter.Value())
+	}
+	assert.DeepEqual(t, got, map[string]int64{
+		"kind0":    2,
+		"kind1":    1,
+		"overflow": 3,
+	})
+}
+
+func TestRegistryErrorKindLimit(t *testing.T) {
+	TrackWindows(true)
+	defer TrackWindows(false)
+	defer readNextMinute()()
+
+	var reg Registry
+	reg.SetErrorKindLimit(1)
+	for i := 0; i < 3; i++ {
+		err := fmt.Errorf("kind%d", i)
+		reg.StartNamed("limited").Stop(&err)
+	}
+	assert.DeepEqual(t, errorCounts(reg.LookupState("limited")), map[string]int64{
+		"kind0":    1,
+		"overflow": 2,
+	})
+	assert.DeepEqual(t, reg.LookupState("limited").WindowErrors(time.Minute), map[string]int64{
+		"kind0":    1,
+		"overflow": 2,
+	})
+
+	// other registries are not limited.
+	var other Registry
+	for i := 0; i < 3; i++ {
+		err := fmt.Errorf("kind%d", i)
+		other.StartNamed("limited").Stop(&err)
+	}
+	assert.Equal(t, len(errorCounts(other.LookupState("limited"))), 3)
+}
@@ -0,0 +1,50 @@
+// +build !nomon
+
+package mon
+
+import (
+	"sync"
+	"unsafe"
+)
+
+// Cursor hands out the changes to a registry since its previous read. Each
+// consumer, like an exporter or a dashboard, can have its own named Cursor
+// without resetting anything for the others, unlike Collect.
+type Cursor struct {
+	reg  *Registry
+	mu   sync.Mutex
+	last *Snapshot
+}
+
+// GetCursor returns the Cursor for the consumer name in the Default registry,
+// allocating a new one if necessary.
+func GetCursor(name string) *Cursor { return Default.GetCursor(name) }
+
+// RemoveCursor removes the Cursor for the consumer name from the Default registry.
+func RemoveCursor(name string) { Default.RemoveCursor(name) }
+
+// GetCursor returns the Cursor for the consumer name, allocating a new one if
+// necessary.
+func (r *Registry) GetCursor(name string) *Cursor {
+	return (*Cursor)(r.cursors.Upsert(name, func() unsafe.Pointer {
+		return unsafe.Pointer(&Cursor{reg: r})
+	}))
+}
+
+// RemoveCursor removes the Cursor for the consumer name so that the next call
+// to GetCursor returns a new one.
+func (r *Registry) RemoveCursor(name string) { r.cursors.Delete(name) }
+
+// Read returns the changes to the registry since the previous call to Read as a
+// snapshot returned by Snapshot.Sub. The first call returns a full snapshot.
+func (c *Cursor) Read() *Snapshot {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	snap, last := c.reg.Snapshot(), c.last
+	c.last = snap
+	if last == nil {
+		return snap
+	}
+	return snap.Sub(last)
+}
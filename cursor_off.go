@@ -0,0 +1,22 @@
+// +build nomon
+
+package mon
+
+// Cursor hands out the changes to a registry since its previous read. With
+// the nomon tag, there are never any changes.
+type Cursor struct{}
+
+// GetCursor returns the Cursor for the consumer name in the Default registry.
+func GetCursor(name string) *Cursor { return new(Cursor) }
+
+// RemoveCursor removes the Cursor for the consumer name from the Default registry.
+func RemoveCursor(name string) {}
+
+// GetCursor returns the Cursor for the consumer name.
+func (r *Registry) GetCursor(name string) *Cursor { return new(Cursor) }
+
+// RemoveCursor removes the Cursor for the consumer name.
+func (r *Registry) RemoveCursor(name string) {}
+
+// Read returns the changes to the registry since the previous call to Read.
+func (c *Cursor) Read() *Snapshot { return new(Snapshot) }
@@ -0,0 +1,30 @@
+// +build !nomon
+
+package mon
+
+import (
+	"testing"
+
+	"github.com/zeebo/assert"
+)
+
+func TestCursor(t *testing.T) {
+	var reg Registry
+	reg.GetCounter("counter").Add(5)
+
+	c1, c2 := reg.GetCursor("first"), reg.GetCursor("second")
+	assert.Equal(t, reg.GetCursor("first"), c1)
+	assert.Equal(t, c1.Read().Lookup("counter").Count, 5)
+
+	reg.GetCounter("counter").Add(2)
+	assert.Equal(t, c1.Read().Lookup("counter").Count, 2)
+	assert.Equal(t, c1.Read().Lookup("counter").Count, 0)
+
+	// other cursors and the cumulative data are unaffected
+	assert.Equal(t, c2.Read().Lookup("counter").Count, 7)
+	assert.Equal(t, reg.GetCounter("counter").Value(), 7)
+
+	reg.RemoveCursor("first")
+	assert.That(t, reg.GetCursor("first") != c1)
+	assert.Equal(t, reg.GetCursor("first").Read().Lookup("counter").Count, 7)
+}
@@ -0,0 +1,54 @@
+// +build !nomon
+
+package mon
+
+import (
+	"sync/atomic"
+	"unsafe"
+
+	"github.com/zeebo/mon/floathist"
+)
+
+func newDistributionState(name string) unsafe.Pointer {
+	s := newMetricState(name, KindDistribution)
+	s.metric.dist = new(floathist.Histogram)
+	return unsafe.Pointer(s)
+}
+
+// Distribution is a State that keeps track of arbitrary values, like payload
+// sizes or batch lengths.
+type Distribution State
+
+// GetDistribution returns the current distribution for some name in the Default
+// registry. See Registry.GetDistribution.
+func GetDistribution(name string, unit Unit) *Distribution {
+	return Default.GetDistribution(name, unit)
+}
+
+// GetDistribution returns the current distribution for some name, allocating a
+// new one if necessary. The first call to specify a unit other than UnitNone
+// decides the unit.
+func (r *Registry) GetDistribution(name string, unit Unit) *Distribution {
+	d := (*Distribution)(r.getStateWith(name, KindDistribution, newDistributionState))
+	if unit != UnitNone && atomic.LoadUint32(&d.metric.unit) == 0 {
+		atomic.CompareAndSwapUint32(&d.metric.unit, 0, uint32(unit))
+	}
+	return d
+}
+
+// Observe records the value into the distribution for the name in the Default registry.
+func Observe(name string, v float64) { Default.Observe(name, v) }
+
+// Observe records the value into the distribution for the name.
+func (r *Registry) Observe(name string, v float64) {
+	r.GetDistribution(name, UnitNone).Observe(v)
+}
+
+// Observe records the value into the distribution.
+func (d *Distribution) Observe(v float64) { d.metric.dist.Observe(float32(v)) }
+
+// Histogram returns the Histogram associated with the distribution.
+func (d *Distribution) Histogram() *floathist.Histogram { return d.metric.dist }
+
+// Unit returns the unit of the values in the distribution.
+func (d *Distribution) Unit() Unit { return Unit(atomic.LoadUint32(&d.metric.unit)) }
@@ -0,0 +1,30 @@
+// +build nomon
+
+package mon
+
+import "github.com/zeebo/mon/floathist"
+
+// Distribution is a State that keeps track of arbitrary values.
+type Distribution State
+
+// GetDistribution returns the current distribution for some name in the Default
+// registry.
+func GetDistribution(name string, unit Unit) *Distribution { return nil }
+
+// Observe records the value into the distribution for the name in the Default registry.
+func Observe(name string, v float64) {}
+
+// GetDistribution returns the current distribution for some name.
+func (r *Registry) GetDistribution(name string, unit Unit) *Distribution { return nil }
+
+// Observe records the value into the distribution for the name.
+func (r *Registry) Observe(name string, v float64) {}
+
+// Observe records the value into the distribution.
+func (d *Distribution) Observe(v float64) {}
+
+// Histogram returns an empty Histogram.
+func (d *Distribution) Histogram() *floathist.Histogram { return new(floathist.Histogram) }
+
+// Unit returns the unit of the values in the distribution.
+func (d *Distribution) Unit() Unit { return UnitNone }
@@ -0,0 +1,163 @@
+// +build !nomon
+
+package mon
+
+import (
+	"sync/atomic"
+	"unsafe"
+
+	"github.com/zeebo/mon/internal/lfht"
+)
+
+// EvictIdle removes idle states and edges from the Default registry. See
+// Registry.EvictIdle.
+func EvictIdle(intervals int) int { return Default.EvictIdle(intervals) }
+
+// EvictIdle removes the timers and edges that have not recorded anything
+// during the last intervals calls to EvictIdle, so that names that are no
+// longer used stop showing up in Times. It is meant to be called periodically,
+// with each call being one interval, and returns the number of states removed.
+//
+// Timers with calls in progress are never removed, so a running Timer always
+// records into a state that is still in the registry, and the next Timer for a
+// removed name uses a new state. Other kinds of states are never removed
+// because callers keep them. A State kept by the caller, as for Record, may be
+// removed, after which it should be looked up again.
+//
+// Removed names leave entries behind in the tables of the registry, so once
+// they outnumber the remaining names, the remaining names are moved into new
+// tables. If a name is created again while being moved, the two states are
+// merged, and calls in progress on the older one are lost.
+func (r *Registry) EvictIdle(intervals int) (evicted int) {
+	r.mu.Lock()
+	token := r.tracker.Acquire()
+	gen := token.Gen()
+	live := int64(0)
+	tables := [...]*lfht.Table{&r.states[gen%2], &r.edges[gen%2]}
+	names := [...]*int64{&r.names[gen%2], &r.edgeNames[gen%2]}
+	for i, table := range tables {
+		for iter := table.Iterator(); iter.Next(); {
+			state := (*State)(iter.Value())
+			if !state.idle(intervals) || !state.evict() {
+				live++
+				continue
+			}
+			if table.CompareAndDelete(iter.Key(), iter.Value()) {
+				atomic.AddInt64(names[i], -1)
+				evicted++
+			} else {
+				atomic.StoreUint32(&state.evicted, 0)
+				live++
+			}
+		}
+	}
+	token.Release()
+
+	r.removed[gen%2] += int64(evicted)
+	if r.removed[gen%2] > live && r.removed[gen%2] >= minCompact {
+		r.compact(gen)
+	}
+	if evicted > 0 {
+		atomic.AddUint64(&r.gen, 1)
+	}
+	r.mu.Unlock()
+	return evicted
+}
+
+// minCompact is the fewest removed entries worth moving the tables for.
+const minCompact = 64
+
+// compact moves the states and edges for the generation into the other pair
+// of tables, leaving behind the entries of removed names. It must be called
+// with the registry mutex held.
+func (r *Registry) compact(gen uint64) {
+	// move everything before switching so that few names can be created in
+	// the new tables before their states are moved.
+	r.moveTables(gen)
+	gen = r.tracker.Increment().Wait()
+	r.moveTables(gen)
+
+	r.states[gen%2] = lfht.Table{}
+	r.edges[gen%2] = lfht.Table{}
+	r.removed[gen%2] = 0
+	atomic.StoreInt64(&r.names[gen%2], 0)
+	atomic.StoreInt64(&r.edgeNames[gen%2], 0)
+}
+
+// moveTables moves the states and edges in the tables for the generation into
+// the other tables, merging them into any state already there.
+func (r *Registry) moveTables(gen uint64) {
+	names := [...]*[2]int64{&r.names, &r.edgeNames}
+	for i, tables := range [...]*[2]lfht.Table{&r.states, &r.edges} {
+		for iter := tables[gen%2].Iterator(); iter.Next(); {
+			state, moved := iter.Value(), false
+			got := tables[(gen+1)%2].Upsert(iter.Key(), func() unsafe.Pointer {
+				moved = true
+				return state
+			})
+			if moved {
+				atomic.AddInt64(&names[i][(gen+1)%2], 1)
+			} else if got != state {
+				atomic.StoreUint32(&(*State)(state).evicted, 1)
+				(*State)(got).merge((*State)(state))
+			}
+		}
+	}
+}
+
+// merge adds everything recorded by another state for the same name.
+func (s *State) merge(o *State) {
+	switch s.kind {
+	case KindGauge:
+		s.carryGauge(o)
+	case KindCounter, KindMeter:
+		atomic.AddInt64(&s.metric.value, atomic.LoadInt64(&o.metric.value))
+	case KindDistribution:
+		s.metric.dist.Merge(o.metric.dist)
+	default:
+		s.timer.his.Merge(&o.timer.his)
+		for iter := o.timer.errors.Iterator(); iter.Next(); {
+			s.countError(iter.Key(), atomic.LoadInt64((*int64)(iter.Value())))
+		}
+	}
+	atomic.AddInt64(&s.folded, atomic.LoadInt64(&o.folded))
+}
+
+// activity returns a value that changes whenever something is recorded.
+func (s *State) activity() int64 {
+	switch s.kind {
+	case KindTimer:
+		return s.timer.his.Total()
+	case KindDistribution:
+		return s.metric.dist.Total()
+	default:
+		return atomic.LoadInt64(&s.metric.value)
+	}
+}
+
+// idle updates the number of intervals the state has gone without recording
+// anything and reports if it is at least n. It must be called with the
+// registry mutex held.
+func (s *State) idle(n int) bool {
+	if s.kind != KindTimer {
+		return false
+	}
+	if activity := s.activity(); activity != s.seen || s.Current() > 0 {
+		s.seen, s.idles = activity, 0
+		return false
+	}
+	s.idles++
+	return s.idles >= n
+}
+
+// evict marks the state as evicted if it has no calls in progress, and
+// reports if it did. Together with start, either the call sees the mark and
+// looks up a new state, or evict sees the call and leaves the state alone.
+func (s *State) evict() bool {
+	atomic.StoreUint32(&s.evicted, 1)
+	if s.Current() != 0 {
+		atomic.StoreUint32(&s.evicted, 0)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,112 @@
+// +build !nomon
+
+package mon
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/zeebo/assert"
+)
+
+func TestEvictIdle(t *testing.T) {
+	t.Run("Basic", func(t *testing.T) {
+		var reg Registry
+		reg.StartNamed("idle").Stop(nil)
+		reg.StartNamed("busy").Stop(nil)
+		reg.GetGauge("gauge").Set(1)
+		counter := reg.GetCounter("counter")
+		running := reg.StartNamed("running")
+
+		assert.Equal(t, reg.EvictIdle(2), 0)
+		reg.StartNamed("busy").Stop(nil)
+		assert.Equal(t, reg.EvictIdle(2), 0)
+		reg.StartNamed("busy").Stop(nil)
+		assert.Equal(t, reg.EvictIdle(2), 1)
+
+		assert.Nil(t, reg.LookupState("idle"))
+		assert.Equal(t, reg.LookupState("busy").Total(), 3)
+		assert.NotNil(t, reg.LookupState("gauge"))
+		assert.NotNil(t, reg.LookupState("running"))
+
+		counter.Inc()
+		assert.Equal(t, reg.GetCounter("counter").Value(), 1)
+
+		running.Stop(nil)
+		reg.StartNamed("idle").Stop(nil)
+		assert.Equal(t, reg.LookupState("idle").Total(), 1)
+	})
+
+	t.Run("Compact", func(t *testing.T) {
+		var reg Registry
+		reg.StartNamed("kept").Stop(nil)
+		for i := 0; i < 2*minCompact; i++ {
+			reg.StartNamed(fmt.Sprint("dynamic-", i)).Stop(nil)
+		}
+		kept := reg.LookupState("kept")
+		running := reg.StartNamed("running")
+
+		for i := 0; i < 3; i++ {
+			reg.StartNamed("kept").Stop(nil)
+			reg.EvictIdle(2)
+		}
+
+		// the removed names triggered a move into the other tables.
+		gen := reg.tracker.Acquire()
+		assert.Equal(t, gen.Gen(), 1)
+		assert.Equal(t, reg.removed[0], 0)
+		gen.Release()
+
+		names := 0
+		reg.Times(func(string, *State) bool { names++; return true })
+		assert.Equal(t, names, 2)
+		assert.Equal(t, reg.LookupState("kept"), kept)
+		assert.Equal(t, kept.Total(), 4)
+
+		running.Stop(nil)
+		assert.Equal(t, reg.LookupState("running").Total(), 1)
+	})
+
+	t.Run("Merge", func(t *testing.T) {
+		s1, s2 := newTimerState("a", nil), newTimerState("a", nil)
+		s1.timer.his.Observe(1)
+		s2.timer.his.Observe(2)
+		s2.countError("kind", 2)
+		s1.merge(s2)
+		assert.Equal(t, s1.Total(), 2)
+		assert.Equal(t, *(*int64)(s1.timer.errors.Lookup("kind")), 2)
+	})
+
+	t.Run("Concurrent", func(t *testing.T) {
+		var reg Registry
+		var wg sync.WaitGroup
+		done := make(chan struct{})
+
+		for i := 0; i < 4; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				for j := 0; ; j++ {
+					select {
+					case <-done:
+						return
+					default:
+					}
+					name := fmt.Sprint("name-", (i+j)%8)
+					timer := reg.StartNamed(name)
+					if reg.LookupState(name) != timer.state {
+						t.Error("running timer state evicted")
+					}
+					timer.Stop(nil)
+				}
+			}(i)
+		}
+
+		for i := 0; i < 1000; i++ {
+			reg.EvictIdle(1)
+		}
+		close(done)
+		wg.Wait()
+	})
+}
@@ -65,6 +65,69 @@ func (h *Histogram) Observe(v float32) {
 	atomic.AddUint64(&l2[(obs>>17)&levelMask], 1)
 }
 
+// getLevel2 returns the counters for the indexes, allocating them if necessary.
+func (h *Histogram) getLevel2(l1i, l2i uint32) *level2 {
+	l1a := (*ptr)(ptr(&h.l0.l1[l1i]))
+	l1 := (*level1)(atomic.LoadPointer(l1a))
+	if l1 == nil {
+		l1 = new(level1)
+		if !atomic.CompareAndSwapPointer(l1a, nil, ptr(l1)) {
+			l1 = (*level1)(atomic.LoadPointer(l1a))
+		} else {
+			h.l0.bm.Set(uint(l1i))
+		}
+	}
+
+	l2a := (*ptr)(ptr(&l1.l2[l2i]))
+	l2 := (*level2)(atomic.LoadPointer(l2a))
+	if l2 == nil {
+		l2 = new(level2)
+		if !atomic.CompareAndSwapPointer(l2a, nil, ptr(l2)) {
+			l2 = (*level2)(atomic.LoadPointer(l2a))
+		} else {
+			l1.bm.Set(uint(l2i))
+		}
+	}
+
+	return l2
+}
+
+// Merge adds all of the observations in other into the histogram.
+func (h *Histogram) Merge(other *Histogram) { h.add(other, 1) }
+
+// Sub removes all of the observations in other from the histogram. Every
+// observation in other must also be in the histogram, as when other is an
+// earlier copy of it made with Merge.
+func (h *Histogram) Sub(other *Histogram) { h.add(other, ^uint64(0)) }
+
+// add adds the counts in other multiplied by the sign into the histogram.
+func (h *Histogram) add(other *Histogram, sign uint64) {
+	bm := other.l0.bm.Clone()
+	for {
+		i, ok := bm.Next()
+		if !ok {
+			break
+		}
+		l1 := (*level1)(atomic.LoadPointer((*ptr)(ptr(&other.l0.l1[i]))))
+
+		bm := l1.bm.Clone()
+		for {
+			j, ok := bm.Next()
+			if !ok {
+				break
+			}
+			ol2 := (*level2)(atomic.LoadPointer((*ptr)(ptr(&l1.l2[j]))))
+			l2 := h.getLevel2(i, j)
+
+			for k := uint32(0); k < levelSize; k++ {
+				if count := atomic.LoadUint64(&ol2[k]); count > 0 {
+					atomic.AddUint64(&l2[k], count*sign)
+				}
+			}
+		}
+	}
+}
+
 func (h *Histogram) Total() (total int64) {
 	bm := h.l0.bm.Clone()
 	for {
@@ -272,3 +335,47 @@ func (h *Histogram) Variance() (sum, avg, vari float64) {
 	}
 	return sum, sum / total, vari / (total - 1)
 }
+
+// Percentiles calls the callback with information about the CDF. The total
+// may increase during the call, but it should never be less than the count.
+func (h *Histogram) Percentiles(cb func(value float32, count, total int64)) {
+	acc, total := int64(0), h.Total()
+
+	bm := h.l0.bm.Clone()
+	for {
+		i, ok := bm.Next()
+		if !ok {
+			break
+		}
+		l1 := (*level1)(atomic.LoadPointer((*ptr)(ptr(&h.l0.l1[i]))))
+
+		bm := l1.bm.Clone()
+		for {
+			j, ok := bm.Next()
+			if !ok {
+				break
+			}
+			l2 := (*level2)(atomic.LoadPointer((*ptr)(ptr(&l1.l2[j]))))
+
+			for k := uint32(0); k < levelSize; k++ {
+				count := int64(atomic.LoadUint64(&l2[k]))
+				if count == 0 {
+					continue
+				}
+
+				obs := i<<27 | j<<22 | k<<17
+				if acc == 0 {
+					lower := obs ^ (^uint32(int32(obs)>>31) | (1 << 31))
+					cb(math.Float32frombits(lower), 0, total)
+				}
+				acc += count
+				if acc > total {
+					total = h.Total()
+				}
+				upper := obs | (1<<17 - 1)
+				upper ^= ^uint32(int32(upper)>>31) | (1 << 31)
+				cb(math.Float32frombits(upper), acc, total)
+			}
+		}
+	}
+}
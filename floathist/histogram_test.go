@@ -34,6 +34,30 @@ func TestQuantile(t *testing.T) {
 		assert.Equal(t, h.CDF(1000), 1.0)
 	})
 
+	t.Run("Percentiles", func(t *testing.T) {
+		h := new(Histogram)
+		for i := float32(-500); i < 500; i++ {
+			h.Observe(i)
+		}
+
+		var values []float32
+		var counts []int64
+		h.Percentiles(func(value float32, count, total int64) {
+			assert.Equal(t, total, 1000)
+			values = append(values, value)
+			counts = append(counts, count)
+		})
+
+		assert.Equal(t, counts[0], 0)
+		assert.Equal(t, counts[len(counts)-1], 1000)
+		for i := 1; i < len(values); i++ {
+			assert.That(t, values[i-1] <= values[i])
+			assert.That(t, counts[i-1] <= counts[i])
+		}
+		assert.That(t, values[0] <= -499)
+		assert.That(t, values[len(values)-1] >= 499)
+	})
+
 	t.Run("Sum", func(t *testing.T) {
 		h := new(Histogram)
 		rsum := float32(0)
@@ -73,6 +97,27 @@ func TestQuantile(t *testing.T) {
 		assert.Equal(t, avg, 499.9786640625)   // 499.5
 		assert.Equal(t, vari, 83433.942757616) // 83416.667
 	})
+
+	t.Run("Merge", func(t *testing.T) {
+		h1, h2, both := new(Histogram), new(Histogram), new(Histogram)
+		for i := float32(0); i < 1000; i++ {
+			h1.Observe(i)
+			both.Observe(i)
+		}
+		for i := float32(-500); i < 500; i++ {
+			h2.Observe(i * i)
+			both.Observe(i * i)
+		}
+
+		h1.Merge(h2)
+		assert.Equal(t, h1.Total(), both.Total())
+		assert.Equal(t, h1.Sum(), both.Sum())
+		assert.DeepEqual(t, h1.Serialize(nil), both.Serialize(nil))
+
+		h1.Sub(h2)
+		assert.Equal(t, h1.Total(), 1000)
+		assert.Equal(t, h1.Sum(), 499978.6640625)
+	})
 }
 
 func BenchmarkHistogram(b *testing.B) {
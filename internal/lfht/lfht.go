@@ -54,11 +54,17 @@ func hash(x string) uintptr {
 type lazyValue struct {
 	value ptr
 	fn    func() ptr
+	kfn   func(string) ptr
+	key   string
 }
 
 func (lv *lazyValue) get() ptr {
 	if lv.value == nil {
-		lv.value = lv.fn()
+		if lv.fn != nil {
+			lv.value = lv.fn()
+		} else {
+			lv.value = lv.kfn(lv.key)
+		}
 	}
 	return lv.value
 }
@@ -102,7 +108,26 @@ func (n *node) getNextRef() *ptr { return &n.next }
 //
 
 func (t *Table) Upsert(k string, vf func() unsafe.Pointer) unsafe.Pointer {
-	return t.upsert(hashedKey{key: k, hash: hash(k)}, lazyValue{fn: vf}).value
+	value := lazyValue{fn: vf}
+	return t.upsert(hashedKey{key: k, hash: hash(k)}, value).revive(&value)
+}
+
+// UpsertKey is like Upsert except the key is passed to the value constructor.
+func (t *Table) UpsertKey(k string, vf func(string) unsafe.Pointer) unsafe.Pointer {
+	value := lazyValue{kfn: vf, key: k}
+	return t.upsert(hashedKey{key: k, hash: hash(k)}, value).revive(&value)
+}
+
+// revive returns the value of the node, replacing it with the lazy value if
+// the node has been deleted.
+func (n *node) revive(value *lazyValue) ptr {
+	for {
+		if v := load(&n.value); v != nil {
+			return v
+		} else if cas(&n.value, nil, value.get()) {
+			return value.value
+		}
+	}
 }
 
 func (t *Table) upsert(key hashedKey, value lazyValue) *node {
@@ -233,10 +258,13 @@ func (n *node) adjustNode(t *Table, r *node, count int) {
 //
 
 func (t *Table) Lookup(k string) unsafe.Pointer {
-	return t.lookup(hashedKey{key: k, hash: hash(k)})
+	if n := t.lookup(hashedKey{key: k, hash: hash(k)}); n != nil {
+		return load(&n.value)
+	}
+	return nil
 }
 
-func (t *Table) lookup(key hashedKey) ptr {
+func (t *Table) lookup(key hashedKey) *node {
 	// if lookup misses are frequent, it may be worthwhile to check
 	// the bitmap to avoid a cache miss loading the bucket.
 	bucket, _ := t.getHashBucket(key.hash)
@@ -250,13 +278,16 @@ func (t *Table) lookup(key hashedKey) ptr {
 	return (*node)(entryRef).lookup(key, t)
 }
 
-func (n *node) lookup(key hashedKey, t *Table) ptr {
+func (n *node) lookup(key hashedKey, t *Table) *node {
 	if n.key == key.key {
-		return n.value
+		return n
 	}
 
 	next := n.getNextRef()
 	nextRef := load(next)
+	if nextRef == tag(t) {
+		return nil
+	}
 	if tagged(nextRef) {
 		prevTable := untag(nextRef)
 		for prevTable.prev != nil && prevTable.prev != t {
@@ -267,12 +298,40 @@ func (n *node) lookup(key hashedKey, t *Table) ptr {
 	return (*node)(nextRef).lookup(key, t)
 }
 
+//
+// delete
+//
+
+// Delete removes the key from the table, returning the value it had. Deleted
+// keys stay in the table with a nil value so that deletion is lock free, and
+// inserting the key again reuses its entry.
+func (t *Table) Delete(k string) unsafe.Pointer {
+	n := t.lookup(hashedKey{key: k, hash: hash(k)})
+	if n == nil {
+		return nil
+	}
+	for {
+		v := load(&n.value)
+		if v == nil || cas(&n.value, v, nil) {
+			return v
+		}
+	}
+}
+
+// CompareAndDelete removes the key from the table if it has the value old,
+// and reports if it did.
+func (t *Table) CompareAndDelete(k string, old unsafe.Pointer) bool {
+	n := t.lookup(hashedKey{key: k, hash: hash(k)})
+	return n != nil && old != nil && cas(&n.value, old, nil)
+}
+
 //
 // iterator
 //
 
 type Iterator struct {
 	n     *node
+	v     ptr
 	top   int
 	stack [_maxLevel]struct {
 		table *Table
@@ -286,7 +345,17 @@ func (t *Table) Iterator() (itr Iterator) {
 	return itr
 }
 
+// Next advances the iterator to the next key that has not been deleted.
 func (i *Iterator) Next() bool {
+	for i.next() {
+		if i.v = load(&i.n.value); i.v != nil {
+			return true
+		}
+	}
+	return false
+}
+
+func (i *Iterator) next() bool {
 next:
 	// if the stack is empty, we're done
 	if i.top < 0 {
@@ -353,7 +422,7 @@ next:
 }
 
 func (i *Iterator) Key() string           { return i.n.key }
-func (i *Iterator) Value() unsafe.Pointer { return i.n.value }
+func (i *Iterator) Value() unsafe.Pointer { return i.v }
 
 //
 // dumping code
@@ -371,7 +440,7 @@ func dumpPointer(indent string, p ptr) {
 	} else if p != nil {
 		n := (*node)(p)
 		p := load(&n.next)
-		fmt.Printf("%snode[%p](key:%q, value:%p, next:%p):\n", indent, n, n.key, n.value, p)
+		fmt.Printf("%snode[%p](key:%q, value:%p, next:%p):\n", indent, n, n.key, load(&n.value), p)
 		if !tagged(p) {
 			dumpPointer(indent+dumpIndent, load(&n.next))
 		}
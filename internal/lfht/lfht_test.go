@@ -3,6 +3,7 @@ package lfht
 import (
 	"runtime"
 	"testing"
+	"unsafe"
 
 	. "github.com/zeebo/mon/internal/tests"
 	"github.com/zeebo/pcg"
@@ -31,6 +32,55 @@ func TestTable(t *testing.T) {
 	}
 }
 
+func TestTable_LookupMissing(t *testing.T) {
+	var ta Table
+	for i := uint32(0); i < 100; i++ {
+		ta.Upsert(Key(i), Empty)
+	}
+	for i := uint32(100); i < 1000; i++ {
+		if ta.Lookup(Key(i)) != nil {
+			ta.dump()
+			t.Fatal(i)
+		}
+	}
+}
+
+func TestTable_Delete(t *testing.T) {
+	var ta Table
+	for i := uint32(0); i < 100; i++ {
+		ta.Upsert(Key(i), Empty)
+	}
+	for i := uint32(0); i < 100; i += 2 {
+		if ta.Delete(Key(i)) != Value {
+			ta.dump()
+			t.Fatal(i)
+		}
+	}
+	if ta.Delete(Key(0)) != nil || ta.CompareAndDelete(Key(1), Ptr(1)) {
+		t.Fatal("deleted wrong value")
+	}
+	for i := uint32(0); i < 100; i++ {
+		if got := ta.Lookup(Key(i)); (got == nil) != (i%2 == 0) {
+			ta.dump()
+			t.Fatal(i, got)
+		}
+	}
+	for iter := ta.Iterator(); iter.Next(); {
+		if iter.Value() != Value || iter.Key() == Key(0) {
+			t.Fatal(iter.Key(), iter.Value())
+		}
+	}
+	if !ta.CompareAndDelete(Key(1), Value) || ta.Lookup(Key(1)) != nil {
+		t.Fatal("did not delete")
+	}
+	if ta.Upsert(Key(0), func() unsafe.Pointer { return Ptr(0) }) != Ptr(0) {
+		t.Fatal("did not revive")
+	}
+	if ta.Lookup(Key(0)) != Ptr(0) {
+		t.Fatal("did not revive")
+	}
+}
+
 func TestTable_Iterator(t *testing.T) {
 	for i := 0; i < 100; i++ {
 		var ta Table
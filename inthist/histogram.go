@@ -1,6 +1,7 @@
 package inthist
 
 import (
+	"math"
 	"math/bits"
 	"sync/atomic"
 	"unsafe"
@@ -42,7 +43,15 @@ const ( // histEntriesBits of 6 keeps ~1.5% error.
 
 // histBucket is the type of a histogram bucket.
 type histBucket struct {
-	entries [histEntries]uint32
+	entries  [histEntries]uint32
+	exemplar unsafe.Pointer // *Exemplar most recently observed in the bucket
+}
+
+// Exemplar is an observed value along with an identifier, like a trace or
+// request id, that can be used to find more information about it.
+type Exemplar struct {
+	Value int64
+	ID    string
 }
 
 // loadBucket atomically loads the bucket pointer from the address.
@@ -90,6 +99,9 @@ func bucketEntry(v int64) (bucket, entry uint64) {
 type Histogram struct {
 	bitmap  bitmap.B64      // encodes which buckets are set
 	buckets [64]*histBucket // 64 so that bounds checks can be removed easier
+	sum     int64           // exact sum of observed values
+	max     int64           // exact maximum observed value
+	min     uint64          // bitwise not of the exact minimum observed value
 }
 
 // Observe records the value in the histogram.
@@ -103,15 +115,185 @@ func (h *Histogram) Observe(v int64) {
 
 	b := loadBucket(&h.buckets[bucket])
 	if b == nil {
-		b = new(histBucket)
-		if !casBucket(&h.buckets[bucket], nil, b) {
-			b = loadBucket(&h.buckets[bucket])
-		} else {
-			h.bitmap.Set(uint(bucket))
-		}
+		b = h.newBucket(bucket)
 	}
 
 	atomic.AddUint32(&b.entries[entry], 1)
+	h.observeExact(v, v)
+}
+
+// ObserveN records the value in the histogram n times. It does nothing unless
+// n is positive and at most math.MaxUint32, and the value times n fits in an
+// int64.
+func (h *Histogram) ObserveN(v, n int64) { h.observeN(v, n) }
+
+// ObserveExemplar records the value in the histogram and keeps it as the most
+// recent exemplar for its bucket. At most one exemplar is kept per bucket, and
+// each bucket covers a range of values about as wide as its lower bound.
+func (h *Histogram) ObserveExemplar(v int64, id string) { h.ObserveExemplarN(v, 1, id) }
+
+// ObserveExemplarN is like ObserveExemplar but records the value n times.
+func (h *Histogram) ObserveExemplarN(v, n int64, id string) {
+	if b, ok := h.observeN(v, n); ok {
+		atomic.StorePointer(&b.exemplar, unsafe.Pointer(&Exemplar{Value: v, ID: id}))
+	}
+}
+
+// observeN records the value n times, returning the bucket it was recorded in,
+// and false if it was out of range.
+func (h *Histogram) observeN(v, n int64) (*histBucket, bool) {
+	// upperValue is inlined and constant folded
+	if v < 0 || v > upperValue(histBuckets-1, histEntries-1) || !validCount(v, n) {
+		return nil, false
+	}
+
+	bucket, entry := bucketEntry(v)
+
+	b := loadBucket(&h.buckets[bucket])
+	if b == nil {
+		b = h.newBucket(bucket)
+	}
+
+	atomic.AddUint32(&b.entries[entry], uint32(n))
+	h.observeExact(v, v*n)
+	return b, true
+}
+
+// validCount reports if the value can be recorded n times: the count must fit
+// in a bucket entry and the sum must fit in an int64.
+func validCount(v, n int64) bool {
+	hi, lo := bits.Mul64(uint64(v), uint64(n))
+	return n > 0 && n <= math.MaxUint32 && hi == 0 && lo <= math.MaxInt64
+}
+
+// observeExact updates the exact statistics with the value, adding sum to the sum.
+func (h *Histogram) observeExact(v, sum int64) {
+	atomic.AddInt64(&h.sum, sum)
+	if v > atomic.LoadInt64(&h.max) {
+		h.setMax(v)
+	}
+	if ^uint64(v) > atomic.LoadUint64(&h.min) {
+		h.setMin(v)
+	}
+}
+
+// setMax raises the maximum to v if it is larger.
+func (h *Histogram) setMax(v int64) {
+	for {
+		max := atomic.LoadInt64(&h.max)
+		if v <= max || atomic.CompareAndSwapInt64(&h.max, max, v) {
+			return
+		}
+	}
+}
+
+// setMin lowers the minimum to v if it is smaller.
+func (h *Histogram) setMin(v int64) {
+	for {
+		min := atomic.LoadUint64(&h.min)
+		if ^uint64(v) <= min || atomic.CompareAndSwapUint64(&h.min, min, ^uint64(v)) {
+			return
+		}
+	}
+}
+
+// Min returns the smallest observed value, or 0 if there are none.
+func (h *Histogram) Min() int64 {
+	if min := atomic.LoadUint64(&h.min); min != 0 {
+		return int64(^min)
+	}
+	return 0
+}
+
+// Max returns the largest observed value, or 0 if there are none.
+func (h *Histogram) Max() int64 { return atomic.LoadInt64(&h.max) }
+
+// ExactSum returns the sum of the observed values. Unlike Sum, it is not an
+// estimate.
+func (h *Histogram) ExactSum() int64 { return atomic.LoadInt64(&h.sum) }
+
+// Exemplars calls the callback with the most recent exemplar of every bucket
+// that has one, in increasing order of value.
+func (h *Histogram) Exemplars(cb func(Exemplar)) {
+	bm := h.bitmap.Clone()
+	for {
+		bucket, ok := bm.Next()
+		if !ok {
+			return
+		}
+
+		b := loadBucket(&h.buckets[bucket])
+		if ex := (*Exemplar)(atomic.LoadPointer(&b.exemplar)); ex != nil {
+			cb(*ex)
+		}
+	}
+}
+
+// newBucket allocates the bucket, returning the one that won if there was a race.
+func (h *Histogram) newBucket(bucket uint64) *histBucket {
+	b := new(histBucket)
+	if !casBucket(&h.buckets[bucket%64], nil, b) {
+		return loadBucket(&h.buckets[bucket%64])
+	}
+	h.bitmap.Set(uint(bucket))
+	return b
+}
+
+// Merge adds all of the observations in other into the histogram.
+func (h *Histogram) Merge(other *Histogram) {
+	atomic.AddInt64(&h.sum, other.ExactSum())
+	if other.Total() > 0 {
+		h.setMax(other.Max())
+		h.setMin(other.Min())
+	}
+
+	bm := other.bitmap.Clone()
+	for {
+		bucket, ok := bm.Next()
+		if !ok {
+			return
+		}
+
+		ob := loadBucket(&other.buckets[bucket])
+		b := loadBucket(&h.buckets[bucket])
+		if b == nil {
+			b = h.newBucket(uint64(bucket))
+		}
+		for entry := range ob.entries[:] {
+			if count := atomic.LoadUint32(&ob.entries[entry]); count > 0 {
+				atomic.AddUint32(&b.entries[entry], count)
+			}
+		}
+		if ex := atomic.LoadPointer(&ob.exemplar); ex != nil {
+			atomic.StorePointer(&b.exemplar, ex)
+		}
+	}
+}
+
+// Sub removes all of the observations in other from the histogram. Every
+// observation in other must also be in the histogram, as when other is an
+// earlier copy of it made with Merge. The min, max and exemplars are kept.
+func (h *Histogram) Sub(other *Histogram) {
+	atomic.AddInt64(&h.sum, -other.ExactSum())
+
+	bm := other.bitmap.Clone()
+	for {
+		bucket, ok := bm.Next()
+		if !ok {
+			return
+		}
+
+		ob := loadBucket(&other.buckets[bucket])
+		b := loadBucket(&h.buckets[bucket])
+		if b == nil {
+			continue
+		}
+		for entry := range ob.entries[:] {
+			if count := atomic.LoadUint32(&ob.entries[entry]); count > 0 {
+				atomic.AddUint32(&b.entries[entry], -count)
+			}
+		}
+	}
 }
 
 // Total returns the number of completed calls.
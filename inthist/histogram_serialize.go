@@ -2,11 +2,29 @@ package inthist
 
 import (
 	"encoding/binary"
+	"sync/atomic"
 
 	"github.com/zeebo/errs"
 	"github.com/zeebo/mon/internal/buffer"
 )
 
+const (
+	// serializeMagic is the first byte of versioned serialized data. Data from
+	// before versions were added starts with an action word, which never has
+	// two adjacent skips, so it can never start with this byte.
+	serializeMagic = 0xff
+
+	// serializeVersion is the version of the format written by Serialize.
+	serializeVersion = 1
+
+	// headerSize is the size of the magic byte, the version, and the exact
+	// sum, minimum and maximum at the start of serialized data.
+	headerSize = 2 + 24
+)
+
+// Serialize encodes the histogram into the capacity of dst and returns it. The
+// encoding starts with a magic byte and version, then the exact sum, minimum
+// and maximum, followed by the counts of every entry.
 func (h *Histogram) Serialize(dst []byte) []byte {
 	le := binary.LittleEndian
 
@@ -14,8 +32,15 @@ func (h *Histogram) Serialize(dst []byte) []byte {
 		dst = make([]byte, 128)
 	}
 
-	buf := buffer.Of(dst).Advance(8)
-	aidx := uintptr(0)
+	buf := buffer.Of(dst)
+	*buf.Index(0) = serializeMagic
+	*buf.Index(1) = serializeVersion
+	le.PutUint64(buf.Index8(2)[:], uint64(h.ExactSum()))
+	le.PutUint64(buf.Index8(10)[:], atomic.LoadUint64(&h.min))
+	le.PutUint64(buf.Index8(18)[:], uint64(h.Max()))
+
+	buf = buf.Advance(headerSize + 8)
+	aidx := uintptr(headerSize)
 
 	acount := uint8(0)
 	action := uint64(0)
@@ -113,10 +138,60 @@ func (h *Histogram) Serialize(dst []byte) []byte {
 	return buf.Prefix()
 }
 
+// Load replaces the histogram with the data from Serialize. Data serialized
+// before the exact sum, minimum and maximum were kept is also accepted, and
+// they are estimated from the counts.
 func (h *Histogram) Load(data []byte) (err error) {
 	le := binary.LittleEndian
 	buf := buffer.OfLen(data)
 
+	if buf.Remaining() == 0 || *buf.Index(0) != serializeMagic {
+		if err := h.loadCounts(buf); err != nil {
+			return err
+		}
+		h.estimateExact()
+		return nil
+	}
+
+	if buf.Remaining() < headerSize {
+		return errs.New("invalid encoded data")
+	}
+	if version := *buf.Index(1); version != serializeVersion {
+		return errs.New("unsupported encoding version: %d", version)
+	}
+	atomic.StoreInt64(&h.sum, int64(le.Uint64(buf.Index8(2)[:])))
+	atomic.StoreUint64(&h.min, le.Uint64(buf.Index8(10)[:]))
+	atomic.StoreInt64(&h.max, int64(le.Uint64(buf.Index8(18)[:])))
+
+	return h.loadCounts(buf.Advance(headerSize))
+}
+
+// estimateExact sets the exact sum, minimum and maximum from the counts.
+func (h *Histogram) estimateExact() {
+	atomic.StoreInt64(&h.sum, int64(h.Sum()))
+	atomic.StoreUint64(&h.min, 0)
+	atomic.StoreInt64(&h.max, 0)
+
+	bm := h.bitmap.Clone()
+	for {
+		bucket, ok := bm.Next()
+		if !ok {
+			return
+		}
+
+		b := loadBucket(&h.buckets[bucket])
+		for entry := range b.entries[:] {
+			if atomic.LoadUint32(&b.entries[entry]) > 0 {
+				h.setMin(lowerValue(uint64(bucket), uint64(entry)))
+				h.setMax(upperValue(uint64(bucket), uint64(entry)))
+			}
+		}
+	}
+}
+
+// loadCounts replaces the counts of every entry with the encoded data.
+func (h *Histogram) loadCounts(buf buffer.T) (err error) {
+	le := binary.LittleEndian
 	b := (*histBucket)(nil)
 
 	bi := uint32(0)
@@ -124,6 +124,95 @@ func TestHistogram(t *testing.T) {
 		assert.Equal(t, variance, 83361.358976)
 	})
 
+	t.Run("Merge", func(t *testing.T) {
+		h1, h2, both := new(Histogram), new(Histogram), new(Histogram)
+		for i := int64(0); i < 1000; i++ {
+			h1.Observe(i)
+			both.Observe(i)
+		}
+		for i := int64(0); i < 1000; i++ {
+			h2.Observe(i * i)
+			both.Observe(i * i)
+		}
+
+		h1.Merge(h2)
+		assert.Equal(t, h1.Total(), both.Total())
+		assert.Equal(t, h1.Sum(), both.Sum())
+		assert.DeepEqual(t, h1.Serialize(nil), both.Serialize(nil))
+	})
+
+	t.Run("Sub", func(t *testing.T) {
+		h1, h2, squares := new(Histogram), new(Histogram), new(Histogram)
+		for i := int64(0); i < 1000; i++ {
+			h1.Observe(i)
+		}
+		h2.Merge(h1)
+		for i := int64(0); i < 1000; i++ {
+			h2.Observe(i * i)
+			squares.Observe(i * i)
+		}
+
+		h2.Sub(h1)
+		assert.Equal(t, h2.Total(), squares.Total())
+		assert.Equal(t, h2.ExactSum(), squares.ExactSum())
+		assert.Equal(t, h2.Quantile(0.5), squares.Quantile(0.5))
+		assert.Equal(t, h2.Sum(), squares.Sum())
+	})
+
+	t.Run("Exemplars", func(t *testing.T) {
+		h := new(Histogram)
+		h.ObserveExemplar(10, "a")
+		h.ObserveExemplar(11, "b")
+		h.ObserveExemplar(1000, "c")
+		h.ObserveExemplar(-1, "d")
+		h.Observe(1001)
+
+		var got []Exemplar
+		h.Exemplars(func(ex Exemplar) { got = append(got, ex) })
+		assert.DeepEqual(t, got, []Exemplar{{11, "b"}, {1000, "c"}})
+		assert.Equal(t, h.Total(), 4)
+	})
+
+	t.Run("ObserveN", func(t *testing.T) {
+		h := new(Histogram)
+		h.ObserveN(10, 5)
+		h.ObserveExemplarN(1000, 3, "a")
+		h.ObserveN(-1, 2)
+		h.ObserveN(10, 0)
+		h.ObserveN(10, -1)
+		h.ObserveN(10, 1<<32)
+		h.ObserveN(1<<40, 1<<30)
+
+		assert.Equal(t, h.Total(), 8)
+		assert.Equal(t, h.ExactSum(), 3050)
+		assert.Equal(t, h.Quantile(0.5), 10)
+		assert.Equal(t, h.Quantile(1), 1000)
+	})
+
+	t.Run("Exact", func(t *testing.T) {
+		h := new(Histogram)
+		assert.Equal(t, h.Min(), 0)
+		assert.Equal(t, h.Max(), 0)
+
+		h.Observe(100)
+		h.Observe(7)
+		h.ObserveN(1000, 3)
+		h.Observe(-5)
+
+		assert.Equal(t, h.Min(), 7)
+		assert.Equal(t, h.Max(), 1000)
+		assert.Equal(t, h.ExactSum(), 3107)
+
+		other := new(Histogram)
+		other.Observe(0)
+		other.Observe(5000)
+		h.Merge(other)
+
+		assert.Equal(t, h.Min(), 0)
+		assert.Equal(t, h.Max(), 5000)
+		assert.Equal(t, h.ExactSum(), 8107)
+	})
+
 	t.Run("Percentiles", func(t *testing.T) {
 		h := new(Histogram)
 		for i := int64(0); i < 1000; i++ {
@@ -163,9 +252,39 @@ func TestHistogram(t *testing.T) {
 
 		assert.Equal(t, h.Total(), h2.Total())
 		assert.Equal(t, h.Sum(), h2.Sum())
+		assert.Equal(t, h.ExactSum(), h2.ExactSum())
+		assert.Equal(t, h.Min(), h2.Min())
+		assert.Equal(t, h.Max(), h2.Max())
 		t.Log(h.Average())
 		t.Log(h2.Average())
 	})
+
+	t.Run("LoadUnversioned", func(t *testing.T) {
+		h := new(Histogram)
+		for i := int64(0); i < 10000; i++ {
+			r := int64(pcg.Uint32n(1000) + 500)
+			h.Observe(r)
+		}
+
+		// data from before versions were added lacks the header
+		h2 := new(Histogram)
+		assert.NoError(t, h2.Load(h.Serialize(nil)[headerSize:]))
+
+		assert.Equal(t, h.Total(), h2.Total())
+		assert.Equal(t, h.Sum(), h2.Sum())
+		assert.Equal(t, h2.ExactSum(), int64(h.Sum()))
+		assert.That(t, h2.Min() <= h.Min())
+		assert.That(t, h2.Max() >= h.Max())
+	})
+
+	t.Run("LoadVersion", func(t *testing.T) {
+		h := new(Histogram)
+		h.Observe(100)
+
+		data := h.Serialize(nil)
+		data[1]++
+		assert.Error(t, new(Histogram).Load(data))
+	})
 }
 
 func BenchmarkHistogram(b *testing.B) {
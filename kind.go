@@ -0,0 +1,30 @@
+package mon
+
+// Kind describes what a State records.
+type Kind uint8
+
+const (
+	KindTimer        Kind = iota // durations of calls
+	KindCounter                  // a count that only increases
+	KindGauge                    // a value that is set, or read from a callback
+	KindMeter                    // a count of events and their rate
+	KindDistribution             // arbitrary values with a unit
+)
+
+// String returns a lower case name for the kind.
+func (k Kind) String() string {
+	switch k {
+	case KindTimer:
+		return "timer"
+	case KindCounter:
+		return "counter"
+	case KindGauge:
+		return "gauge"
+	case KindMeter:
+		return "meter"
+	case KindDistribution:
+		return "distribution"
+	default:
+		return "unknown"
+	}
+}
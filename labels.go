@@ -0,0 +1,167 @@
+// +build !nomon
+
+package mon
+
+import (
+	"strconv"
+	"strings"
+	"unsafe"
+)
+
+// Label is a key/value pair that adds a dimension to a State. Keys are made
+// valid label names for the exporters: bytes other than letters, digits and
+// underscores become underscores, and keys that are empty, start with a digit
+// or two underscores, or are used by the exporters, like "name" or "error",
+// are prefixed with "label_". If keys repeat, the last one is used.
+type Label struct {
+	Key   string
+	Value string
+}
+
+// reservedLabels are the label keys used by the exporters themselves.
+var reservedLabels = map[string]bool{
+	"name":     true,
+	"error":    true,
+	"unit":     true,
+	"window":   true,
+	"quantile": true,
+	"budget":   true,
+	"le":       true,
+}
+
+// sanitizeKey returns the key as a valid label name that is not reserved.
+func sanitizeKey(key string) string {
+	valid := key != "" && !isDigit(key[0]) && !strings.HasPrefix(key, "__") && !reservedLabels[key]
+	for i := 0; valid && i < len(key); i++ {
+		valid = isLabelByte(key[i])
+	}
+	if valid {
+		return key
+	}
+
+	var b strings.Builder
+	if key == "" || isDigit(key[0]) || strings.HasPrefix(key, "__") || reservedLabels[key] {
+		b.WriteString("label_")
+	}
+	for i := 0; i < len(key); i++ {
+		if isLabelByte(key[i]) {
+			b.WriteByte(key[i])
+		} else {
+			b.WriteByte('_')
+		}
+	}
+	return b.String()
+}
+
+func isDigit(ch byte) bool { return '0' <= ch && ch <= '9' }
+
+// isLabelByte returns true if the byte may be part of a label name.
+func isLabelByte(ch byte) bool {
+	return ch == '_' || isDigit(ch) || 'a' <= ch && ch <= 'z' || 'A' <= ch && ch <= 'Z'
+}
+
+// sortLabels returns a copy of the labels with sanitized keys, sorted by key,
+// and with only the last of any repeated keys.
+func sortLabels(labels []Label) []Label {
+	sorted := make([]Label, len(labels))
+	for i, label := range labels {
+		sorted[i] = Label{Key: sanitizeKey(label.Key), Value: label.Value}
+	}
+	// insertion sort is stable, so repeated keys stay in order.
+	for i := 1; i < len(sorted); i++ {
+		for j := i; j > 0 && sorted[j].Key < sorted[j-1].Key; j-- {
+			sorted[j], sorted[j-1] = sorted[j-1], sorted[j]
+		}
+	}
+	out := sorted[:0]
+	for _, label := range sorted {
+		if len(out) > 0 && out[len(out)-1].Key == label.Key {
+			out[len(out)-1] = label
+		} else {
+			out = append(out, label)
+		}
+	}
+	return out
+}
+
+// nameKey returns the registry key for a name without labels. Names with
+// braces or backslashes have them escaped with a backslash, so that the key
+// of a name never contains the unescaped brace that starts the labels in the
+// key of a labeled state.
+func nameKey(name string) string {
+	if strings.IndexByte(name, '{') < 0 && strings.IndexByte(name, '\\') < 0 {
+		return name
+	}
+	var b strings.Builder
+	for i := 0; i < len(name); i++ {
+		if name[i] == '{' || name[i] == '\\' {
+			b.WriteByte('\\')
+		}
+		b.WriteByte(name[i])
+	}
+	return b.String()
+}
+
+// labeledKey returns the registry key for a name and a sorted set of labels.
+// The labels follow the key of the name, sanitized keys cannot contain the
+// separators, and values are quoted, so distinct names and sets of labels
+// have distinct keys, none of which is the key of a name without labels.
+func labeledKey(name string, labels []Label) string {
+	var b strings.Builder
+	b.WriteString(nameKey(name))
+	b.WriteByte('{')
+	for i, label := range labels {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(label.Key)
+		b.WriteByte('=')
+		b.WriteString(strconv.Quote(label.Value))
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+// GetLabeledState returns the current state for some name and set of labels in
+// the Default registry, allocating a new one if necessary.
+func GetLabeledState(name string, labels ...Label) *State {
+	return Default.GetLabeledState(name, labels...)
+}
+
+// LookupLabeledState returns the current state for some name and set of labels
+// in the Default registry, returning nil if none exists.
+func LookupLabeledState(name string, labels ...Label) *State {
+	return Default.LookupLabeledState(name, labels...)
+}
+
+// GetLabeledState returns the current state for some name and set of labels,
+// allocating a new one if necessary. The order of the labels does not matter.
+func (r *Registry) GetLabeledState(name string, labels ...Label) *State {
+	if len(labels) == 0 {
+		return r.GetState(name)
+	}
+	labels = sortLabels(labels)
+	key := labeledKey(name, labels)
+	token := r.tracker.Acquire()
+	gen := token.Gen()
+	var state *State
+	if r.admit(&r.states[gen%2], &r.names[gen%2], key) {
+		state = (*State)(r.states[gen%2].Upsert(key, func() unsafe.Pointer {
+			return unsafe.Pointer(newTimerState(name, labels))
+		}))
+	} else {
+		key, state = OverflowName, r.overflow(gen, KindTimer, newState)
+	}
+	token.Release()
+	r.found(state, &r.shared[0], key)
+	return state.checkKind(KindTimer)
+}
+
+// LookupLabeledState returns the current state for some name and set of labels,
+// returning nil if none exists.
+func (r *Registry) LookupLabeledState(name string, labels ...Label) *State {
+	if len(labels) == 0 {
+		return r.LookupState(name)
+	}
+	return r.LookupKey(labeledKey(name, sortLabels(labels)))
+}
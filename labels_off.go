@@ -0,0 +1,27 @@
+// +build nomon
+
+package mon
+
+// Label is a key/value pair that adds a dimension to a State. Keys are made
+// valid label names for the exporters: bytes other than letters, digits and
+// underscores become underscores, and keys that are empty, start with a digit
+// or two underscores, or are used by the exporters, like "name" or "error",
+// are prefixed with "label_". If keys repeat, the last one is used.
+type Label struct {
+	Key   string
+	Value string
+}
+
+// GetLabeledState returns the current state for some name and set of labels in
+// the Default registry.
+func GetLabeledState(name string, labels ...Label) *State { return nil }
+
+// LookupLabeledState returns the current state for some name and set of labels
+// in the Default registry.
+func LookupLabeledState(name string, labels ...Label) *State { return nil }
+
+// GetLabeledState returns the current state for some name and set of labels.
+func (r *Registry) GetLabeledState(name string, labels ...Label) *State { return nil }
+
+// LookupLabeledState returns the current state for some name and set of labels.
+func (r *Registry) LookupLabeledState(name string, labels ...Label) *State { return nil }
@@ -0,0 +1,61 @@
+// +build !nomon
+
+package mon
+
+import (
+	"testing"
+
+	"github.com/zeebo/assert"
+)
+
+func TestLabels(t *testing.T) {
+	t.Run("Sanitize", func(t *testing.T) {
+		for _, tc := range []struct{ key, want string }{
+			{"route", "route"},
+			{"http.route", "http_route"},
+			{"a=b,c", "a_b_c"},
+			{"1st", "label_1st"},
+			{"", "label_"},
+			{"__meta", "label___meta"},
+			{"name", "label_name"},
+			{"error", "label_error"},
+			{"le", "label_le"},
+		} {
+			assert.Equal(t, sanitizeKey(tc.key), tc.want)
+		}
+	})
+
+	t.Run("Sort", func(t *testing.T) {
+		assert.DeepEqual(t, sortLabels([]Label{{"b", "1"}, {"a", "2"}, {"b", "3"}, {"name", "4"}}),
+			[]Label{{"a", "2"}, {"b", "3"}, {"label_name", "4"}})
+	})
+
+	t.Run("Keys", func(t *testing.T) {
+		var reg Registry
+		st1 := reg.GetLabeledState("x", Label{"a", `1",b="2`})
+		st2 := reg.GetLabeledState("x", Label{"a", "1"}, Label{"b", "2"})
+		st3 := reg.GetLabeledState("x", Label{`a="1",b`, "2"})
+		assert.That(t, st1 != st2 && st2 != st3 && st1 != st3)
+		assert.Equal(t, reg.LookupLabeledState("x", Label{"http.route", "/"}), (*State)(nil))
+		st4 := reg.GetLabeledState("x", Label{"http.route", "/"})
+		assert.Equal(t, reg.LookupLabeledState("x", Label{"http_route", "/"}), st4)
+		assert.DeepEqual(t, st4.Labels(), []Label{{"http_route", "/"}})
+	})
+
+	t.Run("Names", func(t *testing.T) {
+		var reg Registry
+		labeled := reg.GetLabeledState("foo", Label{"a", "1"})
+		bare := reg.GetState(`foo{a="1"}`)
+		assert.That(t, labeled != bare)
+		assert.Equal(t, bare.Name(), `foo{a="1"}`)
+		assert.Equal(t, reg.LookupState(`foo{a="1"}`), bare)
+		assert.Equal(t, reg.LookupKey(`foo{a="1"}`), labeled)
+		assert.Equal(t, reg.LookupKey(`foo\{a="1"}`), bare)
+
+		st1 := reg.GetLabeledState(`x\`, Label{"a", "1"})
+		st2 := reg.GetState(`x{a="1"}`)
+		st3 := reg.GetLabeledState(`x{a="1"}`, Label{"b", "2"})
+		st4 := reg.GetLabeledState("x", Label{"a", "1"})
+		assert.That(t, st1 != st2 && st1 != st3 && st1 != st4 && st2 != st3 && st2 != st4 && st3 != st4)
+	})
+}
@@ -0,0 +1,93 @@
+// +build !nomon
+
+package mon
+
+import (
+	"sync/atomic"
+	"unsafe"
+
+	"github.com/zeebo/mon/internal/lfht"
+)
+
+// OverflowName is the name of the state that timers for new names are folded
+// into once a registry has too many names. Other kinds of states are folded
+// into a state named OverflowName followed by a dot and the kind, like
+// "mon.overflow.counter". Edges are folded into the edge from OverflowName to
+// itself.
+const OverflowName = "mon.overflow"
+
+// overflowNames maps each kind to the name of its overflow state.
+var overflowNames = [...]string{
+	KindTimer:        OverflowName,
+	KindCounter:      OverflowName + "." + KindCounter.String(),
+	KindGauge:        OverflowName + "." + KindGauge.String(),
+	KindMeter:        OverflowName + "." + KindMeter.String(),
+	KindDistribution: OverflowName + "." + KindDistribution.String(),
+}
+
+// SetNameLimit sets the name limit of the Default registry. See
+// Registry.SetNameLimit.
+func SetNameLimit(n int) { Default.SetNameLimit(n) }
+
+// SetNameLimit sets an approximate limit on the number of distinct names,
+// including each distinct set of labels, the registry keeps track of. Once the
+// limit is hit, new names are folded into an overflow state, and the number of
+// lookups folded into it, one for each Timer started for a new name, is
+// available from its Folded method. The edges between names
+// are limited to the same number separately. Like every state, the names are
+// reset by Collect. A limit of zero, the default, means there is no limit.
+func (r *Registry) SetNameLimit(n int) {
+	r.mu.Lock()
+	token := r.tracker.Acquire()
+	gen := token.Gen()
+	atomic.StoreInt64(&r.names[gen%2], countKeys(&r.states[gen%2]))
+	atomic.StoreInt64(&r.edgeNames[gen%2], countKeys(&r.edges[gen%2]))
+	atomic.StoreInt64(&r.limit, int64(n))
+	token.Release()
+	r.mu.Unlock()
+}
+
+// countKeys returns the number of keys in the table.
+func countKeys(table *lfht.Table) (keys int64) {
+	for iter := table.Iterator(); iter.Next(); {
+		keys++
+	}
+	return keys
+}
+
+// admit reports if the key may be stored in the table, counting it in names
+// if it is new.
+func (r *Registry) admit(table *lfht.Table, names *int64, key string) bool {
+	limit := atomic.LoadInt64(&r.limit)
+	if limit <= 0 || table.Lookup(key) != nil {
+		return true
+	}
+	if atomic.AddInt64(names, 1) <= limit {
+		return true
+	}
+	atomic.AddInt64(names, -1)
+	return false
+}
+
+// overflow returns the overflow state for the kind, allocating it with the
+// constructor if necessary, and counts the fold.
+func (r *Registry) overflow(gen uint64, kind Kind, fn func(string) unsafe.Pointer) *State {
+	state := (*State)(r.states[gen%2].UpsertKey(overflowNames[kind], fn))
+	atomic.AddInt64(&state.folded, 1)
+	return state
+}
+
+// overflowEdge returns the overflow edge, allocating it if necessary, and
+// counts the fold.
+func (r *Registry) overflowEdge(gen uint64) *State {
+	state := (*State)(r.edges[gen%2].UpsertKey(edgeKey(OverflowName, OverflowName), newEdgeState))
+	atomic.AddInt64(&state.folded, 1)
+	return state
+}
+
+// Folded returns the number of lookups of new names that were folded into the
+// state because its registry had too many names. Every Timer started for a new
+// name is a lookup, including those from a Thunk or NamedThunk, while a
+// Counter or other metric looked up once and kept counts once. It is only
+// non-zero for overflow states.
+func (s *State) Folded() int64 { return atomic.LoadInt64(&s.folded) }
@@ -0,0 +1,89 @@
+// +build !nomon
+
+package mon
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/zeebo/assert"
+)
+
+func TestNameLimit(t *testing.T) {
+	var reg Registry
+	reg.StartNamed("a").Stop(nil)
+	reg.SetNameLimit(2)
+
+	reg.StartNamed("b").Stop(nil)
+	reg.StartNamed("c").Stop(nil)
+	reg.StartNamed("d").Stop(nil)
+	reg.StartNamed("a").Stop(nil)
+	reg.StartLabeled("b", Label{"k", "v"}).Stop(nil)
+	reg.GetCounter("counter").Inc()
+
+	assert.Equal(t, reg.LookupState("a").Total(), 2)
+	assert.Equal(t, reg.LookupState("b").Total(), 1)
+	assert.Nil(t, reg.LookupState("c"))
+	assert.Nil(t, reg.LookupState("counter"))
+
+	overflow := reg.LookupState(OverflowName)
+	assert.Equal(t, overflow.Total(), 3)
+	assert.Equal(t, overflow.Folded(), 3)
+	counter := reg.LookupState(OverflowName + ".counter")
+	assert.Equal(t, counter.Kind(), KindCounter)
+	assert.Equal(t, counter.Counter().Value(), 1)
+	assert.Equal(t, counter.Folded(), 1)
+
+	reg.Collect(func(string, *State) bool { return true })
+	reg.StartNamed("c").Stop(nil)
+	reg.StartNamed("d").Stop(nil)
+	reg.StartNamed("e").Stop(nil)
+	assert.Equal(t, reg.LookupState("c").Total(), 1)
+	assert.Equal(t, reg.LookupState("d").Total(), 1)
+	assert.Equal(t, reg.LookupState(OverflowName).Folded(), 1)
+
+	reg.SetNameLimit(0)
+	reg.StartNamed("e").Stop(nil)
+	assert.Equal(t, reg.LookupState("e").Total(), 1)
+}
+
+func TestNameLimitThunk(t *testing.T) {
+	// thunks only use the Default registry, so fill it up to the limit.
+	StartNamed("limit-first").Stop(nil)
+	Default.SetNameLimit(1)
+	defer Default.SetNameLimit(0)
+
+	thunk := NamedThunk{Name: "limit-thunk"}
+	thunk.Start().Stop(nil)
+	overflow := LookupState(OverflowName)
+	folded := overflow.Folded()
+	thunk.Start().Stop(nil)
+	thunk.Start().Stop(nil)
+
+	assert.Nil(t, LookupState("limit-thunk"))
+	assert.Equal(t, overflow.Folded(), folded+2)
+}
+
+func TestEdgeLimit(t *testing.T) {
+	var reg Registry
+	reg.SetNameLimit(2)
+
+	ctx, parent := reg.StartNamedContext(context.Background(), "parent")
+	for i := 0; i < 10; i++ {
+		_, child := reg.StartNamedContext(ctx, fmt.Sprint("child", i))
+		child.Stop(nil)
+	}
+	parent.Stop(nil)
+
+	edges := 0
+	reg.Edges(func(parent, child string, state *State) bool {
+		edges++
+		return true
+	})
+	assert.Equal(t, edges, 3)
+
+	overflow := reg.LookupEdge(OverflowName, OverflowName)
+	assert.Equal(t, overflow.Total(), 8)
+	assert.Equal(t, overflow.Folded(), 8)
+}
@@ -0,0 +1,164 @@
+// +build !nomon
+
+package mon
+
+import (
+	"math"
+	"sync/atomic"
+	"unsafe"
+)
+
+func newCounterState(name string) unsafe.Pointer {
+	return unsafe.Pointer(newMetricState(name, KindCounter))
+}
+
+func newGaugeState(name string) unsafe.Pointer {
+	s := newMetricState(name, KindGauge)
+	s.metric.value = unsetGauge
+	return unsafe.Pointer(s)
+}
+
+func newMeterState(name string) unsafe.Pointer {
+	s := newMetricState(name, KindMeter)
+	s.metric.created = nanotime()
+	return unsafe.Pointer(s)
+}
+
+//
+// counters
+//
+
+// Counter is a State that keeps track of a count that only increases.
+type Counter State
+
+// GetCounter returns the current counter for some name in the Default registry,
+// allocating a new one if necessary.
+func GetCounter(name string) *Counter { return Default.GetCounter(name) }
+
+// GetCounter returns the current counter for some name, allocating a new one if necessary.
+func (r *Registry) GetCounter(name string) *Counter {
+	return (*Counter)(r.getStateWith(name, KindCounter, newCounterState))
+}
+
+// Inc adds one to the counter.
+func (c *Counter) Inc() { atomic.AddInt64(&c.metric.value, 1) }
+
+// Add adds delta to the counter. Negative deltas are ignored.
+func (c *Counter) Add(delta int64) {
+	if delta > 0 {
+		atomic.AddInt64(&c.metric.value, delta)
+	}
+}
+
+// Value returns the current count.
+func (c *Counter) Value() int64 { return atomic.LoadInt64(&c.metric.value) }
+
+//
+// gauges
+//
+
+// Gauge is a State that keeps track of a value that can go up and down.
+type Gauge State
+
+// GetGauge returns the current gauge for some name in the Default registry,
+// allocating a new one if necessary.
+func GetGauge(name string) *Gauge { return Default.GetGauge(name) }
+
+// GetGaugeFunc returns the current gauge for some name in the Default registry.
+// See Registry.GetGaugeFunc.
+func GetGaugeFunc(name string, fn func() float64) *Gauge { return Default.GetGaugeFunc(name, fn) }
+
+// GetGauge returns the current gauge for some name, allocating a new one if necessary.
+func (r *Registry) GetGauge(name string) *Gauge {
+	return (*Gauge)(r.getStateWith(name, KindGauge, newGaugeState))
+}
+
+// GetGaugeFunc returns the current gauge for some name, allocating a new one if
+// necessary, and arranges for its value to be read from fn.
+func (r *Registry) GetGaugeFunc(name string, fn func() float64) *Gauge {
+	g := r.GetGauge(name)
+	g.metric.fn.Store(fn)
+	return g
+}
+
+// unsetGauge is the value of a gauge that has not been set or added to. It is
+// a NaN that Set never stores, so that it can not be mistaken for a value.
+const unsetGauge = int64(0x7ff00000000000ff)
+
+// gaugeBits returns the bits of the value to store in a gauge.
+func gaugeBits(v float64) int64 {
+	if v != v {
+		v = math.NaN()
+	}
+	return int64(math.Float64bits(v))
+}
+
+// gaugeValue returns the value of a gauge with the bits.
+func gaugeValue(bits int64) float64 {
+	if bits == unsetGauge {
+		return 0
+	}
+	return math.Float64frombits(uint64(bits))
+}
+
+// Set sets the value of the gauge.
+func (g *Gauge) Set(v float64) { atomic.StoreInt64(&g.metric.value, gaugeBits(v)) }
+
+// Add adds delta to the value of the gauge.
+func (g *Gauge) Add(delta float64) {
+	for {
+		old := atomic.LoadInt64(&g.metric.value)
+		if atomic.CompareAndSwapInt64(&g.metric.value, old, gaugeBits(gaugeValue(old)+delta)) {
+			return
+		}
+	}
+}
+
+// carryGauge copies the value and callback of an older gauge for the same name
+// unless the gauge already has its own.
+func (s *State) carryGauge(old *State) {
+	atomic.CompareAndSwapInt64(&s.metric.value, unsetGauge, atomic.LoadInt64(&old.metric.value))
+	if fn, _ := old.metric.fn.Load().(func() float64); fn != nil && s.metric.fn.Load() == nil {
+		s.metric.fn.Store(fn)
+	}
+}
+
+// Value returns the value of the gauge, calling the callback if there is one.
+func (g *Gauge) Value() float64 {
+	if fn, _ := g.metric.fn.Load().(func() float64); fn != nil {
+		return fn()
+	}
+	return gaugeValue(atomic.LoadInt64(&g.metric.value))
+}
+
+//
+// meters
+//
+
+// Meter is a State that keeps track of the count and rate of some events.
+type Meter State
+
+// GetMeter returns the current meter for some name in the Default registry,
+// allocating a new one if necessary.
+func GetMeter(name string) *Meter { return Default.GetMeter(name) }
+
+// GetMeter returns the current meter for some name, allocating a new one if necessary.
+func (r *Registry) GetMeter(name string) *Meter {
+	return (*Meter)(r.getStateWith(name, KindMeter, newMeterState))
+}
+
+// Mark records that n events happened.
+func (m *Meter) Mark(n int64) { atomic.AddInt64(&m.metric.value, n) }
+
+// Count returns the number of events that have happened.
+func (m *Meter) Count() int64 { return atomic.LoadInt64(&m.metric.value) }
+
+// Rate returns the number of events per second since the meter was created.
+// Because Collect replaces every meter, it is the rate since the last Collect.
+func (m *Meter) Rate() float64 {
+	elapsed := float64(nanotime()-m.metric.created) / 1e9
+	if elapsed <= 0 {
+		return 0
+	}
+	return float64(m.Count()) / elapsed
+}
@@ -0,0 +1,63 @@
+// +build nomon
+
+package mon
+
+// Counter is a State that keeps track of a count that only increases.
+type Counter State
+
+// GetCounter returns the current counter for some name in the Default registry.
+func GetCounter(name string) *Counter { return nil }
+
+// GetCounter returns the current counter for some name.
+func (r *Registry) GetCounter(name string) *Counter { return nil }
+
+// Inc adds one to the counter.
+func (c *Counter) Inc() {}
+
+// Add adds delta to the counter. Negative deltas are ignored.
+func (c *Counter) Add(delta int64) {}
+
+// Value returns the current count.
+func (c *Counter) Value() int64 { return 0 }
+
+// Gauge is a State that keeps track of a value that can go up and down.
+type Gauge State
+
+// GetGauge returns the current gauge for some name in the Default registry.
+func GetGauge(name string) *Gauge { return nil }
+
+// GetGaugeFunc returns the current gauge for some name in the Default registry.
+func GetGaugeFunc(name string, fn func() float64) *Gauge { return nil }
+
+// GetGauge returns the current gauge for some name.
+func (r *Registry) GetGauge(name string) *Gauge { return nil }
+
+// GetGaugeFunc returns the current gauge for some name.
+func (r *Registry) GetGaugeFunc(name string, fn func() float64) *Gauge { return nil }
+
+// Set sets the value of the gauge.
+func (g *Gauge) Set(v float64) {}
+
+// Add adds delta to the value of the gauge.
+func (g *Gauge) Add(delta float64) {}
+
+// Value returns the value of the gauge.
+func (g *Gauge) Value() float64 { return 0 }
+
+// Meter is a State that keeps track of the count and rate of some events.
+type Meter State
+
+// GetMeter returns the current meter for some name in the Default registry.
+func GetMeter(name string) *Meter { return nil }
+
+// GetMeter returns the current meter for some name.
+func (r *Registry) GetMeter(name string) *Meter { return nil }
+
+// Mark records that n events happened.
+func (m *Meter) Mark(n int64) {}
+
+// Count returns the number of events that have happened.
+func (m *Meter) Count() int64 { return 0 }
+
+// Rate returns the number of events per second.
+func (m *Meter) Rate() float64 { return 0 }
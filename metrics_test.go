@@ -0,0 +1,119 @@
+// +build !nomon
+
+package mon
+
+import (
+	"testing"
+
+	"github.com/zeebo/assert"
+)
+
+func TestMetrics(t *testing.T) {
+	// gauges survive Collect, so use a separate registry to not affect other tests.
+	reg := new(Registry)
+
+	reg.GetCounter("counter").Inc()
+	reg.GetCounter("counter").Add(2)
+	reg.GetCounter("counter").Add(-5)
+	reg.GetGauge("gauge").Set(2.5)
+	reg.GetGauge("gauge").Add(1)
+	reg.GetGaugeFunc("gauge_func", func() float64 { return 5 })
+	reg.GetMeter("meter").Mark(10)
+
+	assert.Equal(t, reg.GetCounter("counter").Value(), 3)
+	assert.Equal(t, reg.GetGauge("gauge").Value(), 3.5)
+	assert.Equal(t, reg.GetGauge("gauge_func").Value(), 5.0)
+	assert.Equal(t, reg.GetMeter("meter").Count(), 10)
+	assert.That(t, reg.GetMeter("meter").Rate() > 0)
+
+	kinds := make(map[string]Kind)
+	reg.Collect(func(name string, st *State) bool {
+		kinds[name] = st.Kind()
+		return true
+	})
+	assert.DeepEqual(t, kinds, map[string]Kind{
+		"counter":    KindCounter,
+		"gauge":      KindGauge,
+		"gauge_func": KindGauge,
+		"meter":      KindMeter,
+	})
+
+	kinds = make(map[string]Kind)
+	reg.Times(func(name string, st *State) bool {
+		kinds[name] = st.Kind()
+		return true
+	})
+	assert.DeepEqual(t, kinds, map[string]Kind{
+		"gauge":      KindGauge,
+		"gauge_func": KindGauge,
+	})
+	assert.Equal(t, reg.GetGauge("gauge").Value(), 3.5)
+}
+
+func TestGaugeCollectRace(t *testing.T) {
+	reg := new(Registry)
+	reg.GetGauge("gauge").Set(2.5)
+	reg.GetGaugeFunc("gauge_func", func() float64 { return 5 })
+
+	// getting the gauges during Collect creates them in the next generation
+	// before they are carried forward.
+	reg.Collect(func(name string, st *State) bool {
+		reg.GetGauge(name)
+		return true
+	})
+	assert.Equal(t, reg.GetGauge("gauge").Value(), 2.5)
+	assert.Equal(t, reg.GetGauge("gauge_func").Value(), 5.0)
+
+	// a gauge set to zero before it is carried forward keeps the zero.
+	reg.Collect(func(name string, st *State) bool {
+		if name == "gauge" {
+			reg.GetGauge(name).Set(0)
+		}
+		return true
+	})
+	assert.Equal(t, reg.GetGauge("gauge").Value(), 0.0)
+}
+
+func TestKindMismatch(t *testing.T) {
+	reg := new(Registry)
+	reg.StartNamed("timer").Stop(nil)
+	reg.GetCounter("counter").Inc()
+
+	assert.That(t, panics(func() { reg.GetCounter("timer") }))
+	assert.That(t, panics(func() { reg.GetGauge("counter") }))
+	assert.That(t, panics(func() { reg.StartNamed("counter") }))
+	assert.That(t, !panics(func() { reg.GetCounter("counter") }))
+	assert.Equal(t, reg.LookupState("timer").Kind(), KindTimer)
+}
+
+// panics returns true if the function panics.
+func panics(fn func()) (panicked bool) {
+	defer func() { panicked = recover() != nil }()
+	fn()
+	return false
+}
+
+func TestDistribution(t *testing.T) {
+	defer Collect(func(string, *State) bool { return true })
+
+	for i := 0; i < 100; i++ {
+		Observe("dist", float64(i))
+	}
+	GetDistribution("dist", UnitBytes).Observe(100)
+	GetDistribution("dist", UnitRatio)
+
+	st := LookupState("dist")
+	assert.Equal(t, st.Kind(), KindDistribution)
+	assert.Equal(t, st.Unit(), UnitBytes)
+	assert.Equal(t, st.Distribution().Histogram().Total(), 101)
+	assert.Equal(t, GetState("timer").Unit(), UnitNanoseconds)
+}
+
+func TestUnit(t *testing.T) {
+	assert.Equal(t, UnitNone.Format(1.5), "1.5")
+	assert.Equal(t, UnitNanoseconds.Format(1500), "1.5µs")
+	assert.Equal(t, UnitBytes.Format(512), "512B")
+	assert.Equal(t, UnitBytes.Format(1536), "1.50KiB")
+	assert.Equal(t, UnitBytes.Format(3<<30), "3.00GiB")
+	assert.Equal(t, UnitRatio.Format(0.125), "12.5%")
+}
@@ -1,3 +1,5 @@
+// +build !nomon
+
 package monflux
 
 import (
@@ -5,6 +7,7 @@ import (
 	"io"
 	"math"
 	"sync/atomic"
+	"time"
 
 	"github.com/zeebo/mon"
 )
@@ -12,27 +15,64 @@ import (
 type Collector struct {
 	Measurement       string
 	ExcludeHistograms bool
+
+	// Registry is the registry to read from. If nil, mon.Default is used.
+	Registry *mon.Registry
+}
+
+// registry returns the registry the Collector reads from.
+func (c Collector) registry() *mon.Registry {
+	if c.Registry == nil {
+		return mon.Default
+	}
+	return c.Registry
 }
 
 func (c Collector) Write(w io.Writer) error {
 	ew := &errWriter{w: w}
-	mon.Times(func(name string, state *mon.State) bool {
+	c.registry().Times(func(_ string, state *mon.State) bool {
 		var m string
 		if c.Measurement != "" {
-			m = fmt.Sprintf("%q,name=%q", c.Measurement, name)
+			m = fmt.Sprintf("%q,name=%q", c.Measurement, state.Name())
 		} else {
-			m = fmt.Sprintf("%q", name)
+			m = fmt.Sprintf("%q", state.Name())
+		}
+		for _, label := range state.Labels() {
+			m += fmt.Sprintf(",%s=%q", label.Key, label.Value)
+		}
+		if folded := state.Folded(); folded > 0 {
+			fmt.Fprintf(ew, "%s folded=%di\n", m, folded)
+		}
+
+		switch state.Kind() {
+		case mon.KindCounter:
+			fmt.Fprintf(ew, "%s value=%di\n", m, state.Counter().Value())
+			return ew.err == nil
+		case mon.KindGauge:
+			// line protocol has no NaN, so gauges without a number are skipped.
+			if v := state.Gauge().Value(); !math.IsNaN(v) {
+				fmt.Fprintf(ew, "%s value=%v\n", m, finite(v))
+			}
+			return ew.err == nil
+		case mon.KindMeter:
+			fmt.Fprintf(ew, "%s count=%di,rate=%v\n", m, state.Meter().Count(), state.Meter().Rate())
+			return ew.err == nil
+		case mon.KindDistribution:
+			c.writeDistribution(ew, m, state)
+			return ew.err == nil
 		}
 
 		total := state.Total()
-		fmt.Fprintf(ew, "%s total=%di\n", m, total)
+		fmt.Fprintf(ew, "%s total=%di,current=%di,peak=%di,sample_rate=%di\n",
+			m, total, state.Current(), state.Peak(), state.SampleRate())
 		for iter := state.Errors().Iterator(); iter.Next(); {
 			err, count := iter.Key(), atomic.LoadInt64((*int64)(iter.Value()))
 			fmt.Fprintf(ew, "%s,error=%q count=%di\n", m, err, count)
 		}
 
 		if _, average := state.Average(); !math.IsNaN(average) {
-			fmt.Fprintf(ew, "%s average=%v\n", m, average/1e9)
+			fmt.Fprintf(ew, "%s average=%v,sum=%v,min=%v,max=%v\n", m, average/1e9,
+				float64(state.ExactSum())/1e9, float64(state.Min())/1e9, float64(state.Max())/1e9)
 
 			if !c.ExcludeHistograms {
 				his := state.Histogram()
@@ -50,11 +90,104 @@ func (c Collector) Write(w io.Writer) error {
 			}
 		}
 
+		writeWindows(ew, m, state)
 		return ew.err == nil
 	})
+	if ew.err == nil {
+		c.writeObjectives(ew)
+	}
 	return ew.err
 }
 
+func (c Collector) writeObjectives(w io.Writer) {
+	for _, status := range c.registry().Objectives() {
+		var m string
+		if c.Measurement != "" {
+			m = fmt.Sprintf("%q,name=%q", c.Measurement, status.Name)
+		} else {
+			m = fmt.Sprintf("%q", status.Name)
+		}
+
+		window := "all"
+		for _, win := range windows {
+			if win.dur == status.Window {
+				window = win.label
+			}
+		}
+
+		fmt.Fprintf(w, "%s,window=%s slo_total=%di,slo_latency_compliance=%v,slo_error_ratio=%v,"+
+			"slo_latency_burn=%v,slo_error_burn=%v\n",
+			m, window, status.Total, status.LatencyCompliance, status.ErrorRatio,
+			finite(status.LatencyBurn), finite(status.ErrorBurn))
+	}
+}
+
+// finite clamps infinite values, which line protocol cannot represent.
+func finite(v float64) float64 {
+	return math.Max(-math.MaxFloat64, math.Min(v, math.MaxFloat64))
+}
+
+// windows are the rolling windows written when they are tracked. Each covers
+// that many whole minutes before the current one. See mon.State.Window.
+var windows = []struct {
+	label string
+	dur   time.Duration
+}{
+	{"1m", time.Minute},
+	{"5m", 5 * time.Minute},
+	{"15m", 15 * time.Minute},
+}
+
+func writeWindows(w io.Writer, m string, state *mon.State) {
+	for _, window := range windows {
+		his := state.Window(window.dur)
+		if his == nil {
+			return
+		}
+
+		total := his.Total()
+		if total == 0 {
+			fmt.Fprintf(w, "%s,window=%s total=%di\n", m, window.label, total)
+			continue
+		}
+
+		_, average := his.Average()
+		fmt.Fprintf(w, "%s,window=%s total=%di,average=%v,p50=%v,p90=%v,p99=%v,p999=%v\n",
+			m, window.label, total, average/1e9,
+			float64(his.Quantile(0.5))/1e9, float64(his.Quantile(0.9))/1e9,
+			float64(his.Quantile(0.99))/1e9, float64(his.Quantile(0.999))/1e9)
+	}
+}
+
+func (c Collector) writeDistribution(w io.Writer, m string, state *mon.State) {
+	if unit := state.Unit().String(); unit != "" {
+		m += fmt.Sprintf(",unit=%s", unit)
+	}
+
+	his := state.Distribution().Histogram()
+	total := his.Total()
+	fmt.Fprintf(w, "%s total=%di\n", m, total)
+	if total == 0 {
+		return
+	}
+
+	_, average := his.Average()
+	fmt.Fprintf(w, "%s average=%v\n", m, average)
+
+	if !c.ExcludeHistograms {
+		outputq := func(q float64) {
+			fmt.Fprintf(w, "%s,percentile=%v value=%v\n", m, q, his.Quantile(q))
+		}
+
+		outputq(0)
+		outputq(0.5)
+		for i, p := int64(10), float64(0.1); i/2 < total; i, p = i*10, p/10 {
+			outputq(1 - p)
+		}
+		outputq(1)
+	}
+}
+
 func Write(w io.Writer) error { return Collector{}.Write(w) }
 
 type errWriter struct {
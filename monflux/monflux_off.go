@@ -0,0 +1,22 @@
+// +build nomon
+
+package monflux
+
+import (
+	"io"
+
+	"github.com/zeebo/mon"
+)
+
+type Collector struct {
+	Measurement       string
+	ExcludeHistograms bool
+
+	// Registry is the registry to read from. If nil, mon.Default is used.
+	Registry *mon.Registry
+}
+
+// Write writes nothing.
+func (c Collector) Write(w io.Writer) error { return nil }
+
+func Write(w io.Writer) error { return nil }
@@ -1,9 +1,14 @@
+// +build !nomon
+
 package monflux
 
 import (
 	"bytes"
 	"errors"
+	"math"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/zeebo/mon"
 )
@@ -17,6 +22,14 @@ func TestMetrics(t *testing.T) {
 		}()
 	}
 
+	mon.TrackWindows(true)
+	defer mon.TrackWindows(false)
+	mon.StartLabeled("labeled", mon.Label{Key: "route", Value: "/"}).Stop(nil)
+	mon.GetCounter("counter").Inc()
+	mon.GetGauge("gauge").Set(1.5)
+	mon.GetMeter("meter").Mark(10)
+	mon.GetDistribution("size", mon.UnitBytes).Observe(1024)
+
 	done := make(chan struct{})
 	defer close(done)
 	go func() {
@@ -34,3 +47,65 @@ func TestMetrics(t *testing.T) {
 
 	t.Logf("\n%s", buf.String())
 }
+
+func TestRegistry(t *testing.T) {
+	var reg mon.Registry
+	reg.StartNamed("private").Stop(nil)
+	mon.StartNamed("public").Stop(nil)
+	defer mon.Collect(func(string, *mon.State) bool { return true })
+
+	var buf bytes.Buffer
+	if err := (Collector{Registry: &reg, ExcludeHistograms: true}).Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), `"private"`) || strings.Contains(buf.String(), `"public"`) {
+		t.Fatalf("wrong registry:\n%s", buf.String())
+	}
+}
+
+func TestGaugeValues(t *testing.T) {
+	var reg mon.Registry
+	reg.GetGauge("nan").Set(math.NaN())
+	reg.GetGauge("inf").Set(math.Inf(1))
+
+	var buf bytes.Buffer
+	if err := (Collector{Registry: &reg}).Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(buf.String(), "NaN") || strings.Contains(buf.String(), "Inf") ||
+		!strings.Contains(buf.String(), `"inf" value=1.7976931348623157e+308`) {
+		t.Fatalf("invalid gauge values:\n%s", buf.String())
+	}
+}
+
+func TestFolded(t *testing.T) {
+	var reg mon.Registry
+	reg.SetNameLimit(1)
+	reg.StartNamed("first").Stop(nil)
+	reg.StartNamed("second").Stop(nil)
+
+	var buf bytes.Buffer
+	if err := (Collector{Registry: &reg, ExcludeHistograms: true}).Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), `"mon.overflow" folded=1i`) {
+		t.Fatalf("missing folded count:\n%s", buf.String())
+	}
+}
+
+func TestObjectives(t *testing.T) {
+	var reg mon.Registry
+	reg.SetObjective(mon.Objective{Name: "api", Latency: time.Hour, LatencyTarget: 0.99, MaxErrorRatio: 0.5})
+	problem := errors.New("problem")
+	reg.StartNamed("api").Stop(nil)
+	reg.StartNamed("api").Stop(&problem)
+
+	var buf bytes.Buffer
+	if err := (Collector{Registry: &reg, ExcludeHistograms: true}).Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	want := `"api",window=all slo_total=2i,slo_latency_compliance=1,slo_error_ratio=0.5,slo_latency_burn=0,slo_error_burn=1`
+	if !strings.Contains(buf.String(), want) {
+		t.Fatalf("missing objective:\n%s", buf.String())
+	}
+}
@@ -0,0 +1,46 @@
+// +build !nomon
+
+package monhandler
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/zeebo/mon"
+)
+
+// WriteCallGraph writes the call graph of mon.Default. See Handler.WriteCallGraph.
+func WriteCallGraph(w io.Writer) error { return Handler{}.WriteCallGraph(w) }
+
+// WriteCallGraph writes the parent to child edges captured by context timers
+// as a graphviz DOT graph. Edges are labeled with their call count and average
+// duration.
+func (h Handler) WriteCallGraph(w io.Writer) error {
+	ew := &errWriter{w: w}
+	fmt.Fprintln(ew, "digraph mon {")
+	fmt.Fprintln(ew, "\tnode [shape=box];")
+	h.registry().Edges(func(parent, child string, st *mon.State) bool {
+		_, avg := st.Average()
+		label := fmt.Sprintf("%d calls\navg %s", st.Total(), st.Unit().Format(avg))
+		fmt.Fprintf(ew, "\t%s -> %s [label=%s];\n",
+			strconv.Quote(parent), strconv.Quote(child), strconv.Quote(label))
+		return ew.err == nil
+	})
+	fmt.Fprintln(ew, "}")
+	return ew.err
+}
+
+type errWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (e *errWriter) Write(p []byte) (int, error) {
+	if e.err != nil {
+		return 0, e.err
+	}
+	var n int
+	n, e.err = e.w.Write(p)
+	return n, e.err
+}
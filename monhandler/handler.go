@@ -1,45 +1,138 @@
+// +build !nomon
+
 package monhandler
 
 import (
 	"bytes"
 	"fmt"
+	"html"
 	"math"
 	"net/http"
+	"net/url"
 	"strconv"
 	"strings"
 	"time"
 
 	chart "github.com/wcharczuk/go-chart"
 	"github.com/zeebo/mon"
+	"github.com/zeebo/mon/floathist"
 	"github.com/zeebo/mon/inthist"
 )
 
-// Handler serves information about collected metrics.
-type Handler struct{}
+// Handler serves information about collected metrics. The root path serves
+// tables of every metric, the call graph in DOT format with ?view=callgraph,
+// the most recent slow calls with ?view=slow, or the status of objectives with
+// ?view=slo. Any other path serves a chart of the metric stored under that
+// key, as linked from the tables. See mon.Registry.LookupKey.
+type Handler struct {
+	// Registry is the registry to read from. If nil, mon.Default is used.
+	Registry *mon.Registry
+}
+
+// registry returns the registry the Handler reads from.
+func (h Handler) registry() *mon.Registry {
+	if h.Registry == nil {
+		return mon.Default
+	}
+	return h.Registry
+}
+
+func (h Handler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	reg := h.registry()
 
-func (Handler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 	if req.URL.Path == "/" || req.URL.Path == "" {
+		switch req.URL.Query().Get("view") {
+		case "callgraph":
+			w.Header().Set("Content-Type", "text/vnd.graphviz; charset=utf-8")
+			_ = h.WriteCallGraph(w)
+			return
+		case "slow":
+			w.Header().Set("Content-Type", "text/html")
+			_ = h.WriteSlowCalls(w)
+			return
+		case "slo":
+			w.Header().Set("Content-Type", "text/html")
+			_ = h.WriteObjectives(w)
+			return
+		}
+
 		w.Header().Set("Content-Type", "text/html")
 		fmt.Fprintln(w, `<meta charset="UTF-8">`)
+		fmt.Fprintln(w, `<p><a href="?view=callgraph">call graph</a> <a href="?view=slow">slow calls</a> <a href="?view=slo">objectives</a></p>`)
+		reg.Times(func(_ string, st *mon.State) bool {
+			if folded := st.Folded(); folded > 0 {
+				fmt.Fprintf(w, "<p>name limit reached: %d calls folded into %s</p>\n",
+					folded, html.EscapeString(st.Name()))
+			}
+			return true
+		})
 		fmt.Fprintln(w, "<table border=1>")
-		fmt.Fprintln(w, "<tr><td>name</td><td>total</td><td>sum</td><td>average</td><td>variance</td><td>stddev</td></tr>")
-		mon.Times(func(name string, st *mon.State) bool {
+		fmt.Fprintln(w, "<tr><td>name</td><td>labels</td><td>current</td><td>peak</td><td>sample rate</td><td>total</td><td>sum</td><td>min</td><td>max</td><td>average</td><td>variance</td><td>stddev</td><td>p99 (1m)</td><td>p99 (5m)</td><td>p99 (15m)</td></tr>")
+		reg.Times(func(key string, st *mon.State) bool {
+			if st.Kind() != mon.KindTimer {
+				return true
+			}
+			unit := st.Unit()
 			total := st.Total()
-			sum, avg, vari := st.Variance()
-			fmt.Fprintf(w, `<tr><td><a href="%[1]s">%[1]s</a></td><td>%d</td><td>%v</td><td>%v</td><td>%v</td><td>%v</td></tr>`,
-				name, total, time.Duration(sum), time.Duration(avg), time.Duration(vari), time.Duration(math.Sqrt(vari)))
+			_, avg, vari := st.Variance()
+			fmt.Fprintf(w, `<tr><td><a href="%s">%s</a></td><td>%s</td><td>%d</td><td>%d</td><td>1/%d</td><td>%d</td><td>%v</td><td>%v</td><td>%v</td><td>%v</td><td>%v</td><td>%v</td>`,
+				url.PathEscape(key), html.EscapeString(st.Name()), html.EscapeString(formatLabels(st.Labels())), st.Current(), st.Peak(), st.SampleRate(), total,
+				unit.Format(float64(st.ExactSum())), unit.Format(float64(st.Min())), unit.Format(float64(st.Max())),
+				unit.Format(avg), unit.Format(vari), unit.Format(math.Sqrt(vari)))
+			for _, d := range []time.Duration{time.Minute, 5 * time.Minute, 15 * time.Minute} {
+				if his := st.Window(d); his != nil && his.Total() > 0 {
+					fmt.Fprintf(w, "<td>%v</td>", unit.Format(float64(his.Quantile(0.99))))
+				} else {
+					fmt.Fprint(w, "<td></td>")
+				}
+			}
+			fmt.Fprintln(w, "</tr>")
 			return true
 		})
+		fmt.Fprintln(w, "</table>")
+
+		fmt.Fprintln(w, "<table border=1>")
+		fmt.Fprintln(w, "<tr><td>name</td><td>unit</td><td>total</td><td>sum</td><td>average</td><td>stddev</td></tr>")
+		reg.Times(func(key string, st *mon.State) bool {
+			if st.Kind() != mon.KindDistribution {
+				return true
+			}
+			unit, his := st.Unit(), st.Distribution().Histogram()
+			sum, avg, vari := his.Variance()
+			fmt.Fprintf(w, `<tr><td><a href="%s">%s</a></td><td>%s</td><td>%d</td><td>%v</td><td>%v</td><td>%v</td></tr>`,
+				url.PathEscape(key), html.EscapeString(st.Name()), unit, his.Total(),
+				unit.Format(sum), unit.Format(avg), unit.Format(math.Sqrt(vari)))
+			return true
+		})
+		fmt.Fprintln(w, "</table>")
+
+		fmt.Fprintln(w, "<table border=1>")
+		fmt.Fprintln(w, "<tr><td>name</td><td>kind</td><td>value</td><td>rate</td></tr>")
+		reg.Times(func(_ string, st *mon.State) bool {
+			var value, rate interface{} = "", ""
+			switch st.Kind() {
+			case mon.KindCounter:
+				value = st.Counter().Value()
+			case mon.KindGauge:
+				value = st.Gauge().Value()
+			case mon.KindMeter:
+				value, rate = st.Meter().Count(), st.Meter().Rate()
+			default:
+				return true
+			}
+			fmt.Fprintf(w, "<tr><td>%s</td><td>%v</td><td>%v</td><td>%v</td></tr>\n",
+				html.EscapeString(st.Name()), st.Kind(), value, rate)
+			return true
+		})
+		fmt.Fprintln(w, "</table>")
 		return
 	}
 
-	state := mon.LookupState(req.URL.Path[1:])
+	state := reg.LookupKey(req.URL.Path[1:])
 	if state == nil {
 		http.Error(w, "not found", http.StatusNotFound)
 		return
 	}
-	his := state.Histogram()
-
 	width, height, pow := 1300, 300, -1
 	if qpow, err := strconv.ParseInt(req.URL.Query().Get("pow"), 10, 0); err == nil {
 		pow = int(qpow)
@@ -51,13 +144,29 @@ func (Handler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 		height = int(qheight)
 	}
 
+	var ch *chart.Chart
+	if state.Kind() == mon.KindDistribution {
+		ch = MakeDistributionChart(width, height, pow, state.Unit(), state.Distribution().Histogram())
+	} else {
+		ch = MakeChart(width, height, pow, state.Histogram())
+	}
+
 	var buf bytes.Buffer
-	_ = MakeChart(width, height, pow, his).Render(chart.SVG, &buf)
+	_ = ch.Render(chart.SVG, &buf)
 
 	w.Header().Set("Content-Type", chart.ContentTypeSVG)
 	_, _ = w.Write(fixupViewbox(buf.Bytes(), width, height))
 }
 
+// formatLabels formats the labels of a state like key="value", key="value".
+func formatLabels(labels []mon.Label) string {
+	parts := make([]string, len(labels))
+	for i, label := range labels {
+		parts[i] = label.Key + "=" + strconv.Quote(label.Value)
+	}
+	return strings.Join(parts, ", ")
+}
+
 func fixupViewbox(data []byte, width, height int) []byte {
 	parts := bytes.SplitN(data, []byte(">"), 2)
 	if len(parts) != 2 {
@@ -82,7 +191,58 @@ func getLabel(i int) string {
 	}
 }
 
+// percentiles calls the callback with information about the CDF of some histogram.
+type percentiles func(cb func(value float64, count, total int64))
+
+// note is an annotation placed on a chart at some percentile and value.
+type note struct {
+	ptile, value float64
+	label        string
+}
+
+// MakeChart returns a chart of the CDFs of the duration histograms, annotated
+// with any exemplars they contain.
 func MakeChart(width, height, pow int, hiss ...*inthist.Histogram) *chart.Chart {
+	pcts := make([]percentiles, 0, len(hiss))
+	var notes []note
+	for _, his := range hiss {
+		his := his
+		pcts = append(pcts, func(cb func(value float64, count, total int64)) {
+			his.Percentiles(func(value, count, total int64) { cb(float64(value), count, total) })
+		})
+		his.Exemplars(func(ex inthist.Exemplar) {
+			notes = append(notes, note{
+				ptile: his.CDF(ex.Value),
+				value: float64(ex.Value),
+				label: ex.ID,
+			})
+		})
+	}
+	return makeChart(width, height, pow, mon.UnitNanoseconds, pcts, notes)
+}
+
+// MakeDistributionChart returns a chart of the CDFs of the value histograms,
+// formatting the values with the unit.
+func MakeDistributionChart(width, height, pow int, unit mon.Unit, hiss ...*floathist.Histogram) *chart.Chart {
+	pcts := make([]percentiles, 0, len(hiss))
+	for _, his := range hiss {
+		his := his
+		pcts = append(pcts, func(cb func(value float64, count, total int64)) {
+			his.Percentiles(func(value float32, count, total int64) { cb(float64(value), count, total) })
+		})
+	}
+	return makeChart(width, height, pow, unit, pcts, nil)
+}
+
+// logScale maps a percentile onto the log x axis of a chart with pow nines.
+func logScale(ptile float64, pow int) float64 {
+	if ptile >= 1 {
+		return float64(pow)
+	}
+	return math.Log10(1 / (1 - ptile))
+}
+
+func makeChart(width, height, pow int, unit mon.Unit, pcts []percentiles, notes []note) *chart.Chart {
 	type line struct {
 		x, y []float64
 	}
@@ -95,14 +255,14 @@ func MakeChart(width, height, pow int, hiss ...*inthist.Histogram) *chart.Chart
 		largest = 1.0 - math.Pow(0.1, float64(pow))
 	}
 
-	for _, his := range hiss {
+	for _, pct := range pcts {
 		var l line
-		his.Percentiles(func(value, count, total int64) {
+		pct(func(value float64, count, total int64) {
 			ptile := float64(count) / float64(total)
 			if ptile <= largest {
 				t = float64(total)
 				l.x = append(l.x, ptile)
-				l.y = append(l.y, float64(value))
+				l.y = append(l.y, value)
 			}
 		})
 		lines = append(lines, l)
@@ -153,11 +313,7 @@ func MakeChart(width, height, pow int, hiss ...*inthist.Histogram) *chart.Chart
 	// log scale the x axis
 	for _, l := range lines {
 		for i, v := range l.x {
-			if v == 1 {
-				l.x[i] = float64(pow)
-			} else {
-				l.x[i] = math.Log10(1 / (1 - v))
-			}
+			l.x[i] = logScale(v, pow)
 		}
 	}
 
@@ -183,7 +339,7 @@ func MakeChart(width, height, pow int, hiss ...*inthist.Histogram) *chart.Chart
 			AxisType:  chart.YAxisSecondary,
 			NameStyle: chart.StyleShow(),
 			ValueFormatter: func(x interface{}) string {
-				return time.Duration(int64(x.(float64))).String()
+				return unit.Format(x.(float64))
 			},
 
 			Style:          chart.StyleShow(),
@@ -205,5 +361,27 @@ func MakeChart(width, height, pow int, hiss ...*inthist.Histogram) *chart.Chart
 		})
 	}
 
+	var annotations []chart.Value2
+	for _, n := range notes {
+		if n.ptile > largest {
+			continue
+		}
+		annotations = append(annotations, chart.Value2{
+			XValue: logScale(n.ptile, pow),
+			YValue: n.value,
+			Label:  n.label,
+		})
+	}
+	if len(annotations) > 0 {
+		ch.Series = append(ch.Series, chart.AnnotationSeries{
+			Style: chart.Style{
+				Show:        true,
+				FontSize:    8,
+				StrokeColor: chart.ColorBlack,
+			},
+			Annotations: annotations,
+		})
+	}
+
 	return ch
 }
@@ -0,0 +1,61 @@
+// +build nomon
+
+package monhandler
+
+import (
+	"io"
+	"net/http"
+
+	chart "github.com/wcharczuk/go-chart"
+	"github.com/zeebo/mon"
+	"github.com/zeebo/mon/floathist"
+	"github.com/zeebo/mon/inthist"
+)
+
+// Handler serves information about collected metrics. With the nomon tag,
+// there are none, so it always responds with not found.
+type Handler struct {
+	// Registry is the registry to read from. If nil, mon.Default is used.
+	Registry *mon.Registry
+}
+
+func (h Handler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	http.Error(w, "not found", http.StatusNotFound)
+}
+
+// WriteCallGraph writes an empty call graph.
+func WriteCallGraph(w io.Writer) error { return Handler{}.WriteCallGraph(w) }
+
+// WriteCallGraph writes an empty call graph.
+func (h Handler) WriteCallGraph(w io.Writer) error {
+	_, err := io.WriteString(w, "digraph mon {\n}\n")
+	return err
+}
+
+// WriteSlowCalls writes an empty table of slow calls.
+func WriteSlowCalls(w io.Writer) error { return Handler{}.WriteSlowCalls(w) }
+
+// WriteSlowCalls writes an empty table of slow calls.
+func (h Handler) WriteSlowCalls(w io.Writer) error {
+	_, err := io.WriteString(w, "<table border=1>\n</table>\n")
+	return err
+}
+
+// WriteObjectives writes an empty table of objectives.
+func WriteObjectives(w io.Writer) error { return Handler{}.WriteObjectives(w) }
+
+// WriteObjectives writes an empty table of objectives.
+func (h Handler) WriteObjectives(w io.Writer) error {
+	_, err := io.WriteString(w, "<table border=1>\n</table>\n")
+	return err
+}
+
+// MakeChart returns an empty chart.
+func MakeChart(width, height, pow int, hiss ...*inthist.Histogram) *chart.Chart {
+	return &chart.Chart{Width: width, Height: height}
+}
+
+// MakeDistributionChart returns an empty chart.
+func MakeDistributionChart(width, height, pow int, unit mon.Unit, hiss ...*floathist.Histogram) *chart.Chart {
+	return &chart.Chart{Width: width, Height: height}
+}
@@ -1,3 +1,5 @@
+// +build !nomon
+
 package monhandler
 
 import (
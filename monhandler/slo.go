@@ -0,0 +1,39 @@
+// +build !nomon
+
+package monhandler
+
+import (
+	"fmt"
+	"html"
+	"io"
+)
+
+// WriteObjectives writes the objectives of mon.Default. See Handler.WriteObjectives.
+func WriteObjectives(w io.Writer) error { return Handler{}.WriteObjectives(w) }
+
+// WriteObjectives writes an HTML table of how every objective is doing over
+// each window, along with the burn rates of its error budgets.
+func (h Handler) WriteObjectives(w io.Writer) error {
+	ew := &errWriter{w: w}
+	fmt.Fprintln(ew, `<meta charset="UTF-8">`)
+	fmt.Fprintln(ew, "<table border=1>")
+	fmt.Fprintln(ew, "<tr><td>name</td><td>window</td><td>total</td><td>latency compliance</td><td>latency burn</td><td>error ratio</td><td>error burn</td><td>status</td></tr>")
+	for _, status := range h.registry().Objectives() {
+		window, met := "all", "met"
+		if status.Window > 0 {
+			window = status.Window.String()
+		}
+		if !status.Met() {
+			met = "<b>violated</b>"
+		}
+		fmt.Fprintf(ew, "<tr><td>%s</td><td>%s</td><td>%d</td><td>%.4f (%s &le; %v)</td><td>%.2f</td><td>%.4f (max %v)</td><td>%.2f</td><td>%s</td></tr>\n",
+			html.EscapeString(status.Name), window, status.Total,
+			status.LatencyCompliance, ratio(status.LatencyTarget), status.Latency, status.LatencyBurn,
+			status.ErrorRatio, status.MaxErrorRatio, status.ErrorBurn, met)
+	}
+	fmt.Fprintln(ew, "</table>")
+	return ew.err
+}
+
+// ratio formats a ratio as a percentage.
+func ratio(v float64) string { return fmt.Sprintf("%g%%", v*100) }
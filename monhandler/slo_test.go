@@ -0,0 +1,25 @@
+// +build !nomon
+
+package monhandler
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zeebo/assert"
+	"github.com/zeebo/mon"
+)
+
+func TestWriteObjectives(t *testing.T) {
+	var reg mon.Registry
+	reg.SetObjective(mon.Objective{Name: "api", Latency: time.Hour, LatencyTarget: 0.99, MaxErrorRatio: 0.001})
+	problem := errors.New("problem")
+	reg.StartNamed("api").Stop(&problem)
+
+	var buf strings.Builder
+	assert.NoError(t, Handler{Registry: &reg}.WriteObjectives(&buf))
+	assert.That(t, strings.Contains(buf.String(), "<td>api</td><td>all</td><td>1</td>"))
+	assert.That(t, strings.Contains(buf.String(), "violated"))
+}
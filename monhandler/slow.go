@@ -0,0 +1,45 @@
+// +build !nomon
+
+package monhandler
+
+import (
+	"fmt"
+	"html"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/zeebo/mon"
+)
+
+// WriteSlowCalls writes the slow calls of mon.Default. See Handler.WriteSlowCalls.
+func WriteSlowCalls(w io.Writer) error { return Handler{}.WriteSlowCalls(w) }
+
+// WriteSlowCalls writes an HTML table of the most recent slow calls, newest
+// first, including the stacks they started from when they were captured.
+func (h Handler) WriteSlowCalls(w io.Writer) error {
+	ew := &errWriter{w: w}
+	fmt.Fprintln(ew, `<meta charset="UTF-8">`)
+	fmt.Fprintln(ew, "<table border=1>")
+	fmt.Fprintln(ew, "<tr><td>start</td><td>name</td><td>duration</td><td>error</td><td>stack</td></tr>")
+	for _, call := range h.registry().SlowCalls() {
+		name := call.Name
+		if len(call.Labels) > 0 {
+			labels := make([]string, len(call.Labels))
+			for i, label := range call.Labels {
+				labels[i] = fmt.Sprintf("%s=%q", label.Key, label.Value)
+			}
+			name += "{" + strings.Join(labels, ",") + "}"
+		}
+		errText := call.Kind
+		if call.Err != nil {
+			errText = fmt.Sprintf("%s: %v", call.Kind, call.Err)
+		}
+		fmt.Fprintf(ew, "<tr><td>%s</td><td>%s</td><td>%v</td><td>%s</td><td><pre>%s</pre></td></tr>\n",
+			call.Start.Format(time.RFC3339Nano), html.EscapeString(name),
+			mon.UnitNanoseconds.Format(float64(call.Duration)),
+			html.EscapeString(errText), html.EscapeString(call.Stack))
+	}
+	fmt.Fprintln(ew, "</table>")
+	return ew.err
+}
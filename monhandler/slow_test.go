@@ -0,0 +1,25 @@
+// +build !nomon
+
+package monhandler
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/zeebo/assert"
+	"github.com/zeebo/mon"
+)
+
+func TestWriteSlowCalls(t *testing.T) {
+	var reg mon.Registry
+	reg.SetSlowThreshold(mon.Threshold{Duration: 1, Stack: true})
+	err := errors.New("problem")
+	reg.StartLabeled("slow", mon.Label{Key: "route", Value: "/"}).Stop(&err)
+
+	var buf strings.Builder
+	assert.NoError(t, Handler{Registry: &reg}.WriteSlowCalls(&buf))
+	assert.That(t, strings.Contains(buf.String(), `slow{route=&#34;/&#34;}`))
+	assert.That(t, strings.Contains(buf.String(), "problem"))
+	assert.That(t, strings.Contains(buf.String(), "TestWriteSlowCalls"))
+}
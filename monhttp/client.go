@@ -0,0 +1,170 @@
+// +build !nomon
+
+package monhttp
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+
+	"github.com/zeebo/mon"
+)
+
+// ClientName is the name outbound requests are recorded under. The time until
+// the response headers arrive is also recorded under ClientName+TTFBSuffix,
+// and when tracing, DNS lookups and connections are recorded under
+// ClientName+DNSSuffix and ClientName+ConnectSuffix.
+const ClientName = "http.client"
+
+// Suffixes appended to ClientName for the phases of a request.
+const (
+	TTFBSuffix    = ".ttfb"
+	DNSSuffix     = ".dns"
+	ConnectSuffix = ".connect"
+)
+
+// Transport is an http.RoundTripper that records the requests made with Base.
+// Requests are recorded with labels for their host and operation, and with an
+// error kind for either the transport error or the status class of the
+// response. The total time includes reading the response body, so it is only
+// recorded once the body is read to the end or closed.
+type Transport struct {
+	// Base makes the requests. If nil, http.DefaultTransport is used.
+	Base http.RoundTripper
+
+	// Op returns the operation a request is for, like "get_user". If nil,
+	// the request method is used.
+	Op func(*http.Request) string
+
+	// Trace controls if DNS lookups and connections are timed.
+	Trace bool
+
+	// Registry is the registry to record into. If nil, mon.Default is used.
+	Registry *mon.Registry
+}
+
+// registry returns the registry the Transport records into.
+func (t *Transport) registry() *mon.Registry {
+	if t.Registry == nil {
+		return mon.Default
+	}
+	return t.Registry
+}
+
+// base returns the RoundTripper that makes the requests.
+func (t *Transport) base() http.RoundTripper {
+	if t.Base == nil {
+		return http.DefaultTransport
+	}
+	return t.Base
+}
+
+// op returns the operation the request is for.
+func (t *Transport) op(req *http.Request) string {
+	if t.Op == nil {
+		return req.Method
+	}
+	return t.Op(req)
+}
+
+// RoundTrip makes the request with Base, recording it.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	reg := t.registry()
+	host := mon.Label{Key: "host", Value: req.URL.Host}
+	labels := []mon.Label{host, {Key: "op", Value: t.op(req)}}
+
+	if t.Trace {
+		req = req.WithContext(httptrace.WithClientTrace(req.Context(), newTrace(reg, host)))
+	}
+
+	total := reg.StartLabeled(ClientName, labels...)
+	ttfb := reg.StartLabeled(ClientName+TTFBSuffix, labels...)
+	resp, err := t.base().RoundTrip(req)
+	if err != nil {
+		ttfb.Stop(&err)
+		total.Stop(&err)
+		return nil, err
+	}
+
+	status := error(statusError(resp.StatusCode))
+	ttfb.Stop(&status)
+	if resp.StatusCode == http.StatusSwitchingProtocols {
+		// the body is the upgraded connection, which callers type assert to
+		// an io.ReadWriteCloser, so it is left alone and the request ends.
+		total.Stop(&status)
+		return resp, nil
+	}
+	resp.Body = &responseBody{ReadCloser: resp.Body, timer: total, status: status}
+	return resp, nil
+}
+
+// responseBody records the total time of a request when it is read to the end
+// or closed.
+type responseBody struct {
+	io.ReadCloser
+	once   sync.Once
+	timer  mon.Timer
+	status error
+}
+
+// stop records the total time with the error, or the status if it is nil.
+func (b *responseBody) stop(err error) {
+	b.once.Do(func() {
+		if err == nil {
+			err = b.status
+		}
+		b.timer.Stop(&err)
+	})
+}
+
+func (b *responseBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if err == io.EOF {
+		b.stop(nil)
+	} else if err != nil {
+		b.stop(err)
+	}
+	return n, err
+}
+
+func (b *responseBody) Close() error {
+	b.stop(nil)
+	return b.ReadCloser.Close()
+}
+
+// newTrace returns a ClientTrace that times DNS lookups and connections.
+func newTrace(reg *mon.Registry, host mon.Label) *httptrace.ClientTrace {
+	var (
+		mu       sync.Mutex
+		dns      mon.Timer
+		connects = make(map[string]mon.Timer)
+	)
+
+	return &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) {
+			mu.Lock()
+			dns = reg.StartLabeled(ClientName+DNSSuffix, host)
+			mu.Unlock()
+		},
+		DNSDone: func(info httptrace.DNSDoneInfo) {
+			mu.Lock()
+			dns.Stop(&info.Err)
+			mu.Unlock()
+		},
+		ConnectStart: func(network, addr string) {
+			mu.Lock()
+			connects[network+" "+addr] = reg.StartLabeled(ClientName+ConnectSuffix, host)
+			mu.Unlock()
+		},
+		ConnectDone: func(network, addr string, err error) {
+			mu.Lock()
+			timer, ok := connects[network+" "+addr]
+			delete(connects, network+" "+addr)
+			mu.Unlock()
+			if ok {
+				timer.Stop(&err)
+			}
+		},
+	}
+}
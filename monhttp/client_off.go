@@ -0,0 +1,36 @@
+// +build nomon
+
+package monhttp
+
+import (
+	"net/http"
+
+	"github.com/zeebo/mon"
+)
+
+// ClientName is the name outbound requests are recorded under.
+const ClientName = "http.client"
+
+// Suffixes appended to ClientName for the phases of a request.
+const (
+	TTFBSuffix    = ".ttfb"
+	DNSSuffix     = ".dns"
+	ConnectSuffix = ".connect"
+)
+
+// Transport is an http.RoundTripper that records the requests made with Base.
+// With the nomon tag, nothing is recorded.
+type Transport struct {
+	Base     http.RoundTripper
+	Op       func(*http.Request) string
+	Trace    bool
+	Registry *mon.Registry
+}
+
+// RoundTrip makes the request with Base.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if t.Base == nil {
+		return http.DefaultTransport.RoundTrip(req)
+	}
+	return t.Base.RoundTrip(req)
+}
@@ -0,0 +1,108 @@
+// +build !nomon
+
+package monhttp
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/zeebo/assert"
+	"github.com/zeebo/mon"
+)
+
+func TestTransport(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "/missing" {
+			http.NotFound(w, req)
+			return
+		}
+		_, _ = w.Write([]byte("hello"))
+	}))
+	defer srv.Close()
+
+	var reg mon.Registry
+	client := &http.Client{Transport: &Transport{
+		Op:       func(req *http.Request) string { return req.URL.Path },
+		Trace:    true,
+		Registry: &reg,
+	}}
+	u, err := url.Parse(srv.URL)
+	assert.NoError(t, err)
+	host := mon.Label{Key: "host", Value: u.Host}
+
+	resp, err := client.Get(srv.URL + "/")
+	assert.NoError(t, err)
+	labels := []mon.Label{host, {Key: "op", Value: "/"}}
+	assert.Equal(t, reg.LookupLabeledState(ClientName+TTFBSuffix, labels...).Total(), 1)
+	assert.Equal(t, reg.LookupLabeledState(ClientName, labels...).Total(), 0)
+
+	data, err := ioutil.ReadAll(resp.Body)
+	assert.NoError(t, err)
+	assert.Equal(t, string(data), "hello")
+	assert.NoError(t, resp.Body.Close())
+	st := reg.LookupLabeledState(ClientName, labels...)
+	assert.Equal(t, st.Total(), 1)
+	assert.Equal(t, errorCount(st, "2xx"), 1)
+	assert.Equal(t, reg.LookupLabeledState(ClientName+ConnectSuffix, host).Total(), 1)
+
+	resp, err = client.Get(srv.URL + "/missing")
+	assert.NoError(t, err)
+	assert.NoError(t, resp.Body.Close())
+	st = reg.LookupLabeledState(ClientName, host, mon.Label{Key: "op", Value: "/missing"})
+	assert.Equal(t, errorCount(st, "4xx"), 1)
+}
+
+type failingTransport struct{}
+
+func (failingTransport) RoundTrip(*http.Request) (*http.Response, error) {
+	return nil, errors.New("refused: no route")
+}
+
+func TestTransportError(t *testing.T) {
+	var reg mon.Registry
+	client := &http.Client{Transport: &Transport{Base: failingTransport{}, Registry: &reg}}
+
+	_, err := client.Get("http://example.invalid/")
+	assert.Error(t, err)
+
+	st := reg.LookupLabeledState(ClientName,
+		mon.Label{Key: "host", Value: "example.invalid"}, mon.Label{Key: "op", Value: "GET"})
+	assert.Equal(t, st.Total(), 1)
+	assert.Equal(t, errorCount(st, "refused"), 1)
+}
+
+type upgradeBody struct{ io.Reader }
+
+func (upgradeBody) Write(p []byte) (int, error) { return len(p), nil }
+func (upgradeBody) Close() error                { return nil }
+
+type upgradingTransport struct{}
+
+func (upgradingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return &http.Response{
+		StatusCode: http.StatusSwitchingProtocols,
+		Body:       upgradeBody{strings.NewReader("")},
+		Request:    req,
+	}, nil
+}
+
+func TestTransportUpgrade(t *testing.T) {
+	var reg mon.Registry
+	client := &http.Client{Transport: &Transport{Base: upgradingTransport{}, Registry: &reg}}
+
+	resp, err := client.Get("http://example.invalid/")
+	assert.NoError(t, err)
+	_, ok := resp.Body.(io.ReadWriteCloser)
+	assert.That(t, ok)
+
+	st := reg.LookupLabeledState(ClientName,
+		mon.Label{Key: "host", Value: "example.invalid"}, mon.Label{Key: "op", Value: "GET"})
+	assert.Equal(t, st.Total(), 1)
+	assert.Equal(t, errorCount(st, "1xx"), 1)
+}
@@ -0,0 +1,299 @@
+// +build !nomon
+
+// Package monhttp records the requests served and made with net/http.
+package monhttp
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+
+	"github.com/zeebo/mon"
+)
+
+// ServerName is the name requests are recorded under when there is no Route.
+const ServerName = "http.server"
+
+// SizeSuffix is appended to the name of the state for a route to get the name
+// of the distribution of its response sizes.
+const SizeSuffix = ".size"
+
+// Middleware times the requests served by a handler. Every response is
+// recorded with an error kind for its status class, like "2xx" or "5xx", and
+// its size is observed in a distribution.
+type Middleware struct {
+	// Route returns the name to record the request under. It should return
+	// a small set of names, like the route patterns of a router, rather than
+	// the request paths. If nil, every request is recorded under ServerName.
+	Route func(*http.Request) string
+
+	// Registry is the registry to record into. If nil, mon.Default is used.
+	Registry *mon.Registry
+}
+
+// registry returns the registry the Middleware records into.
+func (m Middleware) registry() *mon.Registry {
+	if m.Registry == nil {
+		return mon.Default
+	}
+	return m.Registry
+}
+
+// route returns the name to record the request under.
+func (m Middleware) route(req *http.Request) string {
+	if m.Route == nil {
+		return ServerName
+	}
+	return m.Route(req)
+}
+
+// Wrap returns a handler that serves requests with next, recording each one.
+// The request context passed to next has the route as the parent for calls to
+// mon.StartContext.
+func (m Middleware) Wrap(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		reg, name := m.registry(), m.route(req)
+		ctx, timer := reg.StartNamedContext(req.Context(), name)
+		rw := &responseWriter{ResponseWriter: w}
+
+		var err error
+		defer timer.Stop(&err)
+
+		next.ServeHTTP(rw.wrap(), req.WithContext(ctx))
+
+		err = rw.statusError()
+		reg.GetDistribution(name+SizeSuffix, mon.UnitBytes).Observe(float64(rw.size))
+	})
+}
+
+// Wrap returns a handler that serves requests with next, recording each one
+// into the Default registry under the name returned by route. See Middleware.
+func Wrap(next http.Handler, route func(*http.Request) string) http.Handler {
+	return Middleware{Route: route}.Wrap(next)
+}
+
+// statusError is a response status recorded as an error with the kind of its
+// status class.
+type statusError int
+
+// Error returns the status code and text.
+func (e statusError) Error() string {
+	return strconv.Itoa(int(e)) + " " + http.StatusText(int(e))
+}
+
+// Name returns the status class, like "4xx".
+func (e statusError) Name() (string, bool) {
+	return strconv.Itoa(int(e)/100) + "xx", true
+}
+
+// responseWriter keeps track of the status and size of a response.
+type responseWriter struct {
+	http.ResponseWriter
+	status   int
+	size     int64
+	hijacked bool
+}
+
+// statusError returns the error to record for the response.
+func (w *responseWriter) statusError() error {
+	switch {
+	case w.hijacked:
+		return nil
+	case w.status == 0:
+		return statusError(http.StatusOK)
+	default:
+		return statusError(w.status)
+	}
+}
+
+// wrap returns the responseWriter as an http.ResponseWriter that has exactly
+// the optional interfaces of the underlying ResponseWriter, so that handlers
+// checking for them see what the server supports.
+func (w *responseWriter) wrap() http.ResponseWriter {
+	type unwrapper interface{ Unwrap() http.ResponseWriter }
+
+	var has int
+	if _, ok := w.ResponseWriter.(http.Flusher); ok {
+		has |= 1
+	}
+	if _, ok := w.ResponseWriter.(http.Hijacker); ok {
+		has |= 2
+	}
+	if _, ok := w.ResponseWriter.(io.ReaderFrom); ok {
+		has |= 4
+	}
+	if _, ok := w.ResponseWriter.(http.Pusher); ok {
+		has |= 8
+	}
+
+	switch has {
+	case 1:
+		return struct {
+			http.ResponseWriter
+			unwrapper
+			http.Flusher
+		}{w, w, w}
+	case 2:
+		return struct {
+			http.ResponseWriter
+			unwrapper
+			http.Hijacker
+		}{w, w, w}
+	case 1 | 2:
+		return struct {
+			http.ResponseWriter
+			unwrapper
+			http.Flusher
+			http.Hijacker
+		}{w, w, w, w}
+	case 4:
+		return struct {
+			http.ResponseWriter
+			unwrapper
+			io.ReaderFrom
+		}{w, w, w}
+	case 1 | 4:
+		return struct {
+			http.ResponseWriter
+			unwrapper
+			http.Flusher
+			io.ReaderFrom
+		}{w, w, w, w}
+	case 2 | 4:
+		return struct {
+			http.ResponseWriter
+			unwrapper
+			http.Hijacker
+			io.ReaderFrom
+		}{w, w, w, w}
+	case 1 | 2 | 4:
+		return struct {
+			http.ResponseWriter
+			unwrapper
+			http.Flusher
+			http.Hijacker
+			io.ReaderFrom
+		}{w, w, w, w, w}
+	case 8:
+		return struct {
+			http.ResponseWriter
+			unwrapper
+			http.Pusher
+		}{w, w, w}
+	case 1 | 8:
+		return struct {
+			http.ResponseWriter
+			unwrapper
+			http.Flusher
+			http.Pusher
+		}{w, w, w, w}
+	case 2 | 8:
+		return struct {
+			http.ResponseWriter
+			unwrapper
+			http.Hijacker
+			http.Pusher
+		}{w, w, w, w}
+	case 1 | 2 | 8:
+		return struct {
+			http.ResponseWriter
+			unwrapper
+			http.Flusher
+			http.Hijacker
+			http.Pusher
+		}{w, w, w, w, w}
+	case 4 | 8:
+		return struct {
+			http.ResponseWriter
+			unwrapper
+			io.ReaderFrom
+			http.Pusher
+		}{w, w, w, w}
+	case 1 | 4 | 8:
+		return struct {
+			http.ResponseWriter
+			unwrapper
+			http.Flusher
+			io.ReaderFrom
+			http.Pusher
+		}{w, w, w, w, w}
+	case 2 | 4 | 8:
+		return struct {
+			http.ResponseWriter
+			unwrapper
+			http.Hijacker
+			io.ReaderFrom
+			http.Pusher
+		}{w, w, w, w, w}
+	case 1 | 2 | 4 | 8:
+		return struct {
+			http.ResponseWriter
+			unwrapper
+			http.Flusher
+			http.Hijacker
+			io.ReaderFrom
+			http.Pusher
+		}{w, w, w, w, w, w}
+	default:
+		return struct {
+			http.ResponseWriter
+			unwrapper
+		}{w, w}
+	}
+}
+
+// WriteHeader records the first final status, ignoring informational ones
+// like 103 Early Hints that come before it.
+func (w *responseWriter) WriteHeader(status int) {
+	if w.status == 0 && status >= 200 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *responseWriter) Write(p []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(p)
+	w.size += int64(n)
+	return n, err
+}
+
+// ReadFrom copies from r with the underlying ResponseWriter so that it can use
+// sendfile. It is only exposed if the underlying ResponseWriter supports it.
+func (w *responseWriter) ReadFrom(r io.Reader) (int64, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.(io.ReaderFrom).ReadFrom(r)
+	w.size += n
+	return n, err
+}
+
+// Flush flushes the underlying ResponseWriter. It is only exposed if the
+// underlying ResponseWriter supports it.
+func (w *responseWriter) Flush() {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	w.ResponseWriter.(http.Flusher).Flush()
+}
+
+// Hijack hijacks the underlying ResponseWriter. It is only exposed if the
+// underlying ResponseWriter supports it.
+func (w *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	w.hijacked = true
+	return w.ResponseWriter.(http.Hijacker).Hijack()
+}
+
+// Push pushes with the underlying ResponseWriter. It is only exposed if the
+// underlying ResponseWriter supports it.
+func (w *responseWriter) Push(target string, opts *http.PushOptions) error {
+	return w.ResponseWriter.(http.Pusher).Push(target, opts)
+}
+
+// Unwrap returns the underlying ResponseWriter for http.ResponseController.
+func (w *responseWriter) Unwrap() http.ResponseWriter { return w.ResponseWriter }
@@ -0,0 +1,30 @@
+// +build nomon
+
+// Package monhttp records the requests served and made with net/http.
+package monhttp
+
+import (
+	"net/http"
+
+	"github.com/zeebo/mon"
+)
+
+// ServerName is the name requests are recorded under when there is no Route.
+const ServerName = "http.server"
+
+// SizeSuffix is appended to the name of the state for a route to get the name
+// of the distribution of its response sizes.
+const SizeSuffix = ".size"
+
+// Middleware times the requests served by a handler. With the nomon tag,
+// nothing is recorded.
+type Middleware struct {
+	Route    func(*http.Request) string
+	Registry *mon.Registry
+}
+
+// Wrap returns next.
+func (m Middleware) Wrap(next http.Handler) http.Handler { return next }
+
+// Wrap returns next.
+func Wrap(next http.Handler, route func(*http.Request) string) http.Handler { return next }
@@ -0,0 +1,101 @@
+// +build !nomon
+
+package monhttp
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/zeebo/assert"
+	"github.com/zeebo/mon"
+)
+
+func errorCount(st *mon.State, kind string) int64 {
+	if p := st.Errors().Lookup(kind); p != nil {
+		return atomic.LoadInt64((*int64)(p))
+	}
+	return 0
+}
+
+func TestMiddleware(t *testing.T) {
+	var reg mon.Registry
+	handler := Middleware{
+		Route:    func(req *http.Request) string { return "route " + req.URL.Path },
+		Registry: &reg,
+	}.Wrap(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/missing":
+			http.NotFound(w, req)
+		case "/broken":
+			w.WriteHeader(http.StatusInternalServerError)
+		default:
+			_, timer := reg.StartNamedContext(req.Context(), "inner")
+			timer.Stop(nil)
+			_, _ = w.Write([]byte("hello"))
+		}
+	}))
+
+	for _, path := range []string{"/", "/", "/missing", "/broken"} {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", path, nil))
+	}
+
+	st := reg.LookupState("route /")
+	assert.Equal(t, st.Total(), 2)
+	assert.Equal(t, errorCount(st, "2xx"), 2)
+	assert.Equal(t, errorCount(reg.LookupState("route /missing"), "4xx"), 1)
+	assert.Equal(t, errorCount(reg.LookupState("route /broken"), "5xx"), 1)
+	assert.Equal(t, reg.GetDistribution("route /"+SizeSuffix, mon.UnitBytes).Histogram().Total(), 2)
+	assert.Equal(t, reg.GetDistribution("route /"+SizeSuffix, mon.UnitBytes).Histogram().Quantile(1), 5.0)
+	assert.NotNil(t, reg.LookupEdge("route /", "inner"))
+}
+
+func TestMiddlewareDefaultRoute(t *testing.T) {
+	var reg mon.Registry
+	handler := Middleware{Registry: &reg}.Wrap(http.NotFoundHandler())
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+
+	assert.Equal(t, errorCount(reg.LookupState(ServerName), "4xx"), 1)
+}
+
+// flushReaderFrom is a ResponseWriter that supports Flush and ReadFrom but not
+// Hijack or Push.
+type flushReaderFrom struct {
+	header   http.Header
+	statuses []int
+	body     strings.Builder
+}
+
+func (w *flushReaderFrom) Header() http.Header                 { return w.header }
+func (w *flushReaderFrom) WriteHeader(status int)              { w.statuses = append(w.statuses, status) }
+func (w *flushReaderFrom) Write(p []byte) (int, error)         { return w.body.Write(p) }
+func (w *flushReaderFrom) ReadFrom(r io.Reader) (int64, error) { return io.Copy(&w.body, r) }
+func (w *flushReaderFrom) Flush()                              {}
+
+func TestMiddlewareResponseWriter(t *testing.T) {
+	var reg mon.Registry
+	handler := Middleware{Registry: &reg}.Wrap(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		_, flusher := w.(http.Flusher)
+		_, hijacker := w.(http.Hijacker)
+		_, pusher := w.(http.Pusher)
+		rf, readerFrom := w.(io.ReaderFrom)
+		assert.That(t, flusher && readerFrom)
+		assert.That(t, !hijacker && !pusher)
+
+		w.WriteHeader(http.StatusEarlyHints)
+		w.WriteHeader(http.StatusCreated)
+		_, err := rf.ReadFrom(strings.NewReader("hello"))
+		assert.NoError(t, err)
+	}))
+
+	w := &flushReaderFrom{header: make(http.Header)}
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+
+	assert.DeepEqual(t, w.statuses, []int{http.StatusEarlyHints, http.StatusCreated})
+	assert.Equal(t, w.body.String(), "hello")
+	assert.Equal(t, errorCount(reg.LookupState(ServerName), "2xx"), 1)
+	assert.Equal(t, reg.GetDistribution(ServerName+SizeSuffix, mon.UnitBytes).Histogram().Quantile(1), 5.0)
+}
@@ -1,20 +1,54 @@
+// +build !nomon
+
 package monprom
 
 import (
 	"math"
+	"strconv"
 	"sync/atomic"
+	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
 	dto "github.com/prometheus/client_model/go"
 	"github.com/zeebo/mon"
+	"github.com/zeebo/mon/floathist"
 	"github.com/zeebo/mon/inthist"
 )
 
 var (
-	nameLabel  = "name"
-	errorLabel = "error"
+	nameLabel     = "name"
+	errorLabel    = "error"
+	unitLabel     = "unit"
+	windowLabel   = "window"
+	quantileLabel = "quantile"
+	exemplarLabel = "trace_id"
+	budgetLabel   = "budget"
 )
 
+// windows are the rolling windows exported when they are tracked. Each covers
+// that many whole minutes before the current one. See mon.State.Window.
+var windows = []struct {
+	label string
+	dur   time.Duration
+}{
+	{"1m", time.Minute},
+	{"5m", 5 * time.Minute},
+	{"15m", 15 * time.Minute},
+}
+
+// objectiveWindow returns the window label for an objective status.
+func objectiveWindow(d time.Duration) string {
+	for _, window := range windows {
+		if window.dur == d {
+			return window.label
+		}
+	}
+	return "all"
+}
+
+// windowQuantiles are the quantiles exported for each rolling window.
+var windowQuantiles = []string{"0.5", "0.9", "0.99", "0.999"}
+
 func newDesc(name, help string, labels ...string) *prometheus.Desc {
 	ls := append([]string{nameLabel}, labels...)
 	return prometheus.NewDesc("mon_"+name, help, ls, nil)
@@ -22,43 +56,161 @@ func newDesc(name, help string, labels ...string) *prometheus.Desc {
 
 var (
 	descTotal     = newDesc("total", "Total executed")
+	descCurrent   = newDesc("current", "Currently executing")
+	descPeak      = newDesc("peak", "Peak concurrently executing")
 	descErrors    = newDesc("errors", "Count of errors", errorLabel)
 	descAverage   = newDesc("average", "Average of monitored time")
+	descSum       = newDesc("sum", "Sum of monitored time")
+	descMin       = newDesc("min", "Minimum monitored time")
+	descMax       = newDesc("max", "Maximum monitored time")
 	descHistogram = newDesc("histogram", "Histogram of monitored times (milliseconds)")
+	descCounter   = newDesc("counter", "Value of counter")
+	descGauge     = newDesc("gauge", "Value of gauge")
+	descMeter     = newDesc("meter", "Count of metered events")
+	descMeterRate = newDesc("meter_rate", "Rate of metered events per second")
+	descFolded    = newDesc("folded", "Lookups of new names folded into an overflow state by the name limit")
+	descSampling  = newDesc("sample_rate", "One in how many calls are timed")
+
+	descValueTotal     = newDesc("value_total", "Total values observed")
+	descValueAverage   = newDesc("value_average", "Average of observed values")
+	descValueHistogram = newDesc("value_histogram", "Histogram of observed values")
+
+	descWindowTotal    = newDesc("window_total", "Total executed in a rolling window")
+	descWindowAverage  = newDesc("window_average", "Average of monitored time in a rolling window")
+	descWindowQuantile = newDesc("window_quantile", "Quantile of monitored time in a rolling window")
+
+	descObjectiveTotal      = newDesc("slo_total", "Calls evaluated against an objective", windowLabel)
+	descObjectiveCompliance = newDesc("slo_latency_compliance", "Ratio of calls within the latency objective", windowLabel)
+	descObjectiveErrorRatio = newDesc("slo_error_ratio", "Ratio of calls that failed", windowLabel)
+	descObjectiveBurn       = newDesc("slo_burn_rate", "Rate an error budget is spent relative to the objective", windowLabel, budgetLabel)
 )
 
 type Collector struct {
 	ExcludeHistograms bool
+
+	// Registry is the registry to read from. If nil, mon.Default is used.
+	Registry *mon.Registry
 }
 
-func (c Collector) Describe(ch chan<- *prometheus.Desc) {
-	ch <- descTotal
-	ch <- descErrors
-	ch <- descAverage
-	if !c.ExcludeHistograms {
-		ch <- descHistogram
+// registry returns the registry the Collector reads from.
+func (c Collector) registry() *mon.Registry {
+	if c.Registry == nil {
+		return mon.Default
 	}
+	return c.Registry
 }
 
+// Describe sends no descriptors. States may carry arbitrary labels, so the
+// Collector is unchecked.
+func (c Collector) Describe(ch chan<- *prometheus.Desc) {}
+
 func (c Collector) Collect(metrics chan<- prometheus.Metric) {
-	mon.Times(func(name string, state *mon.State) bool {
-		lp := []*dto.LabelPair{{Name: &nameLabel, Value: &name}}
+	c.registry().Times(func(_ string, state *mon.State) bool {
+		lp := labelPairs(state)
+		if folded := state.Folded(); folded > 0 {
+			metrics <- &metric{desc: descFolded, lp: lp, float64: float64(folded)}
+		}
+		switch state.Kind() {
+		case mon.KindCounter:
+			metrics <- &metric{desc: descCounter, lp: lp, float64: float64(state.Counter().Value())}
+			return true
+		case mon.KindGauge:
+			metrics <- &metric{desc: descGauge, lp: lp, float64: state.Gauge().Value()}
+			return true
+		case mon.KindMeter:
+			metrics <- &metric{desc: descMeter, lp: lp, float64: float64(state.Meter().Count())}
+			metrics <- &metric{desc: descMeterRate, lp: lp, float64: state.Meter().Rate()}
+			return true
+		case mon.KindDistribution:
+			if unit := state.Unit().String(); unit != "" {
+				lp = append(lp, &dto.LabelPair{Name: &unitLabel, Value: &unit})
+			}
+			his := state.Distribution().Histogram()
+			_, average := his.Average()
+			metrics <- &metric{desc: descValueTotal, lp: lp, float64: float64(his.Total())}
+			if his.Total() > 0 {
+				metrics <- &metric{desc: descValueAverage, lp: lp, float64: average}
+				if !c.ExcludeHistograms {
+					metrics <- &metric{desc: descValueHistogram, lp: lp, values: his}
+				}
+			}
+			return true
+		}
+
 		_, average := state.Average()
 		metrics <- &metric{desc: descTotal, lp: lp, float64: float64(state.Total())}
+		metrics <- &metric{desc: descCurrent, lp: lp, float64: float64(state.Current())}
+		metrics <- &metric{desc: descPeak, lp: lp, float64: float64(state.Peak())}
+		metrics <- &metric{desc: descSampling, lp: lp, float64: float64(state.SampleRate())}
 		for iter := state.Errors().Iterator(); iter.Next(); {
 			name := iter.Key()
 			errcount := atomic.LoadInt64((*int64)(iter.Value()))
-			lp := append(lp[:1], &dto.LabelPair{Name: &errorLabel, Value: &name})
+			lp := append(lp[:len(lp):len(lp)], &dto.LabelPair{Name: &errorLabel, Value: &name})
 			metrics <- &metric{desc: descErrors, lp: lp, float64: float64(errcount)}
 		}
 		if !math.IsNaN(average) {
 			metrics <- &metric{desc: descAverage, lp: lp, float64: average / 1e9}
+			metrics <- &metric{desc: descSum, lp: lp, float64: float64(state.ExactSum()) / 1e9}
+			metrics <- &metric{desc: descMin, lp: lp, float64: float64(state.Min()) / 1e9}
+			metrics <- &metric{desc: descMax, lp: lp, float64: float64(state.Max()) / 1e9}
 			if !c.ExcludeHistograms {
 				metrics <- &metric{desc: descHistogram, lp: lp, histogram: state.Histogram()}
 			}
 		}
+		c.collectWindows(metrics, lp, state)
 		return true
 	})
+	c.collectObjectives(metrics)
+}
+
+func (c Collector) collectObjectives(metrics chan<- prometheus.Metric) {
+	latency, errs := "latency", "error"
+	for _, status := range c.registry().Objectives() {
+		name, window := status.Name, objectiveWindow(status.Window)
+		lp := []*dto.LabelPair{{Name: &nameLabel, Value: &name}, {Name: &windowLabel, Value: &window}}
+		metrics <- &metric{desc: descObjectiveTotal, lp: lp, float64: float64(status.Total)}
+		metrics <- &metric{desc: descObjectiveCompliance, lp: lp, float64: status.LatencyCompliance}
+		metrics <- &metric{desc: descObjectiveErrorRatio, lp: lp, float64: status.ErrorRatio}
+		metrics <- &metric{desc: descObjectiveBurn, float64: status.LatencyBurn,
+			lp: append(lp[:len(lp):len(lp)], &dto.LabelPair{Name: &budgetLabel, Value: &latency})}
+		metrics <- &metric{desc: descObjectiveBurn, float64: status.ErrorBurn,
+			lp: append(lp[:len(lp):len(lp)], &dto.LabelPair{Name: &budgetLabel, Value: &errs})}
+	}
+}
+
+func (c Collector) collectWindows(metrics chan<- prometheus.Metric, lp []*dto.LabelPair, state *mon.State) {
+	for i := range windows {
+		his := state.Window(windows[i].dur)
+		if his == nil {
+			return
+		}
+
+		lp := append(lp[:len(lp):len(lp)], &dto.LabelPair{Name: &windowLabel, Value: &windows[i].label})
+		total := his.Total()
+		metrics <- &metric{desc: descWindowTotal, lp: lp, float64: float64(total)}
+		if total == 0 {
+			continue
+		}
+
+		_, average := his.Average()
+		metrics <- &metric{desc: descWindowAverage, lp: lp, float64: average / 1e9}
+		for j := range windowQuantiles {
+			q, _ := strconv.ParseFloat(windowQuantiles[j], 64)
+			lp := append(lp[:len(lp):len(lp)], &dto.LabelPair{Name: &quantileLabel, Value: &windowQuantiles[j]})
+			metrics <- &metric{desc: descWindowQuantile, lp: lp, float64: float64(his.Quantile(q)) / 1e9}
+		}
+	}
+}
+
+// labelPairs returns the name label followed by any labels on the state.
+func labelPairs(state *mon.State) []*dto.LabelPair {
+	name, labels := state.Name(), state.Labels()
+	lp := make([]*dto.LabelPair, 0, len(labels)+2)
+	lp = append(lp, &dto.LabelPair{Name: &nameLabel, Value: &name})
+	for i := range labels {
+		lp = append(lp, &dto.LabelPair{Name: &labels[i].Key, Value: &labels[i].Value})
+	}
+	return lp
 }
 
 type metric struct {
@@ -66,6 +218,7 @@ type metric struct {
 	lp        []*dto.LabelPair
 	float64   float64
 	histogram *inthist.Histogram
+	values    *floathist.Histogram
 }
 
 func (m *metric) Desc() *prometheus.Desc { return m.desc }
@@ -74,36 +227,71 @@ func (m *metric) Write(o *dto.Metric) error {
 	o.Label = m.lp
 
 	switch m.desc {
-	case descAverage:
+	case descAverage, descMin, descMax, descCurrent, descPeak, descSampling, descGauge, descMeterRate, descValueAverage,
+		descWindowTotal, descWindowAverage, descWindowQuantile,
+		descObjectiveTotal, descObjectiveCompliance, descObjectiveErrorRatio, descObjectiveBurn:
 		o.Gauge = &dto.Gauge{Value: &m.float64}
 
-	case descTotal, descErrors:
+	case descTotal, descSum, descErrors, descCounter, descMeter, descValueTotal, descFolded:
 		o.Counter = &dto.Counter{Value: &m.float64}
 
 	case descHistogram:
-		his := &dto.Histogram{
-			SampleCount: new(uint64),
-			SampleSum:   new(float64),
-		}
-
-		prevCount := 0.0
-		m.histogram.Percentiles(func(value, count, total int64) {
-			// Update SampleSum and SampleCount
-			fcount, fvalue := float64(count), float64(value)
-			*his.SampleSum += (fcount - prevCount) * fvalue
-			*his.SampleCount = uint64(total)
-			prevCount = fcount
-
-			// Add a bucket
-			ucount := uint64(count)
-			his.Bucket = append(his.Bucket, &dto.Bucket{
-				CumulativeCount: &ucount,
-				UpperBound:      &fvalue,
-			})
+		o.Histogram = newHistogram(func(cb func(value float64, count, total int64)) {
+			m.histogram.Percentiles(func(value, count, total int64) { cb(float64(value), count, total) })
 		})
+		*o.Histogram.SampleSum = float64(m.histogram.ExactSum())
+		addExemplars(o.Histogram, m.histogram)
 
-		o.Histogram = his
+	case descValueHistogram:
+		o.Histogram = newHistogram(func(cb func(value float64, count, total int64)) {
+			m.values.Percentiles(func(value float32, count, total int64) { cb(float64(value), count, total) })
+		})
 	}
 
 	return nil
 }
+
+// addExemplars attaches the exemplars of the histogram to the first bucket that
+// contains them. Exemplars are only available in the OpenMetrics format.
+func addExemplars(his *dto.Histogram, h *inthist.Histogram) {
+	i := 0
+	h.Exemplars(func(ex inthist.Exemplar) {
+		value := float64(ex.Value)
+		for i < len(his.Bucket) && his.Bucket[i].GetUpperBound() < value {
+			i++
+		}
+		if i < len(his.Bucket) {
+			id := ex.ID
+			his.Bucket[i].Exemplar = &dto.Exemplar{
+				Label: []*dto.LabelPair{{Name: &exemplarLabel, Value: &id}},
+				Value: &value,
+			}
+		}
+	})
+}
+
+// newHistogram builds a histogram out of the percentiles of some CDF.
+func newHistogram(percentiles func(cb func(value float64, count, total int64))) *dto.Histogram {
+	his := &dto.Histogram{
+		SampleCount: new(uint64),
+		SampleSum:   new(float64),
+	}
+
+	prevCount := 0.0
+	percentiles(func(value float64, count, total int64) {
+		// Update SampleSum and SampleCount
+		fcount, fvalue := float64(count), value
+		*his.SampleSum += (fcount - prevCount) * fvalue
+		*his.SampleCount = uint64(total)
+		prevCount = fcount
+
+		// Add a bucket
+		ucount := uint64(count)
+		his.Bucket = append(his.Bucket, &dto.Bucket{
+			CumulativeCount: &ucount,
+			UpperBound:      &fvalue,
+		})
+	})
+
+	return his
+}
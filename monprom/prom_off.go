@@ -0,0 +1,21 @@
+// +build nomon
+
+package monprom
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/zeebo/mon"
+)
+
+type Collector struct {
+	ExcludeHistograms bool
+
+	// Registry is the registry to read from. If nil, mon.Default is used.
+	Registry *mon.Registry
+}
+
+// Describe sends no descriptors.
+func (c Collector) Describe(ch chan<- *prometheus.Desc) {}
+
+// Collect sends no metrics.
+func (c Collector) Collect(metrics chan<- prometheus.Metric) {}
@@ -1,9 +1,14 @@
+// +build !nomon
+
 package monprom
 
 import (
 	"bytes"
 	"errors"
+	"fmt"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/common/expfmt"
@@ -19,6 +24,14 @@ func TestMetrics(t *testing.T) {
 		}()
 	}
 
+	mon.TrackWindows(true)
+	defer mon.TrackWindows(false)
+	mon.StartLabeled("labeled", mon.Label{Key: "route", Value: "/"}).Stop(nil)
+	mon.GetCounter("counter").Inc()
+	mon.GetGauge("gauge").Set(1.5)
+	mon.GetMeter("meter").Mark(10)
+	mon.GetDistribution("size", mon.UnitBytes).Observe(1024)
+
 	done := make(chan struct{})
 	defer close(done)
 	go func() {
@@ -45,3 +58,84 @@ func TestMetrics(t *testing.T) {
 
 	t.Logf("\n%s", buf.String())
 }
+
+func TestExemplars(t *testing.T) {
+	for i := 0; i < 100; i++ {
+		mon.StartNamed("exemplars").StopExemplar(nil, fmt.Sprintf("trace-%d", i))
+	}
+
+	reg := prometheus.NewRegistry()
+	reg.Register(Collector{})
+
+	mfs, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	for _, mf := range mfs {
+		if _, err := expfmt.MetricFamilyToOpenMetrics(&buf, mf); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if !strings.Contains(buf.String(), `# {trace_id="trace-99"}`) {
+		t.Fatalf("missing exemplar:\n%s", buf.String())
+	}
+}
+
+func TestObjectives(t *testing.T) {
+	var mreg mon.Registry
+	mreg.SetObjective(mon.Objective{Name: "api", Latency: time.Hour, LatencyTarget: 0.99, MaxErrorRatio: 0.5})
+	problem := errors.New("problem")
+	mreg.StartNamed("api").Stop(nil)
+	mreg.StartNamed("api").Stop(&problem)
+
+	reg := prometheus.NewRegistry()
+	reg.Register(Collector{Registry: &mreg, ExcludeHistograms: true})
+
+	mfs, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	for _, mf := range mfs {
+		if _, err := expfmt.MetricFamilyToText(&buf, mf); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, want := range []string{
+		`mon_slo_error_ratio{name="api",window="all"} 0.5`,
+		`mon_slo_burn_rate{budget="error",name="api",window="all"} 1`,
+		`mon_slo_burn_rate{budget="latency",name="api",window="all"} 0`,
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Fatalf("missing %q:\n%s", want, buf.String())
+		}
+	}
+}
+
+func TestLabelKeys(t *testing.T) {
+	var mreg mon.Registry
+	mreg.StartLabeled("labeled", mon.Label{Key: "http.route", Value: "/"}, mon.Label{Key: "name", Value: "x"}).Stop(nil)
+
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(Collector{Registry: &mreg})
+
+	mfs, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	for _, mf := range mfs {
+		if _, err := expfmt.MetricFamilyToText(&buf, mf); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if !strings.Contains(buf.String(), `http_route="/",label_name="x"`) {
+		t.Fatalf("missing sanitized labels:\n%s", buf.String())
+	}
+}
@@ -0,0 +1,427 @@
+// +build !nomon
+
+// Package monsql records the calls made through database/sql drivers.
+package monsql
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"io"
+
+	"github.com/zeebo/mon"
+)
+
+// The names calls are recorded under. Prepares, execs, queries and rows are
+// labeled with the name of the query, which is the name from WithName or the
+// normalized query. Rows are timed from when the query returns until they are
+// closed.
+const (
+	PrepareName  = "sql.prepare"
+	ExecName     = "sql.exec"
+	QueryName    = "sql.query"
+	RowsName     = "sql.rows"
+	BeginName    = "sql.begin"
+	CommitName   = "sql.commit"
+	RollbackName = "sql.rollback"
+)
+
+// QueryLabel is the key of the label holding the name of the query.
+const QueryLabel = "query"
+
+// nameKey is the context key for the name from WithName.
+type nameKey struct{}
+
+// WithName returns a context that causes queries made with it to be recorded
+// with the name instead of the normalized query.
+func WithName(ctx context.Context, name string) context.Context {
+	return context.WithValue(ctx, nameKey{}, name)
+}
+
+// Driver is a driver.Driver that records the calls made to Base. Optional
+// interfaces that only describe columns, like driver.RowsColumnTypeScanType,
+// are not passed through.
+type Driver struct {
+	// Base is the driver that makes the calls.
+	Base driver.Driver
+
+	// Normalize returns the name for a query that is not named with WithName.
+	// If nil, the package level Normalize is used.
+	Normalize func(query string) string
+
+	// Registry is the registry to record into. If nil, mon.Default is used.
+	Registry *mon.Registry
+}
+
+// Wrap returns a driver.Driver that records the calls made to base into the
+// Default registry. The result can be passed to sql.Register.
+func Wrap(base driver.Driver) driver.Driver { return &Driver{Base: base} }
+
+// registry returns the registry the Driver records into.
+func (d *Driver) registry() *mon.Registry {
+	if d.Registry == nil {
+		return mon.Default
+	}
+	return d.Registry
+}
+
+// queryLabel returns the label for the query made with the context.
+func (d *Driver) queryLabel(ctx context.Context, query string) mon.Label {
+	name, _ := ctx.Value(nameKey{}).(string)
+	if name == "" && d.Normalize != nil {
+		name = d.Normalize(query)
+	} else if name == "" {
+		name = Normalize(query)
+	}
+	return mon.Label{Key: QueryLabel, Value: name}
+}
+
+// start returns a Timer for the name with any labels.
+func (d *Driver) start(name string, labels ...mon.Label) mon.Timer {
+	return d.registry().StartLabeled(name, labels...)
+}
+
+// Open opens a connection with Base.
+func (d *Driver) Open(dsn string) (driver.Conn, error) {
+	c, err := d.Base.Open(dsn)
+	if err != nil {
+		return nil, err
+	}
+	return &conn{d: d, c: c}, nil
+}
+
+// driverError gives the errors from the driver package their own kinds.
+type driverError struct{ err error }
+
+func (e driverError) Error() string { return e.err.Error() }
+func (e driverError) Unwrap() error { return e.err }
+
+// Name returns the kind for the errors from the driver package.
+func (e driverError) Name() (string, bool) {
+	switch e.err {
+	case driver.ErrBadConn:
+		return "bad_conn", true
+	case driver.ErrRemoveArgument:
+		return "remove_argument", true
+	}
+	return "", false
+}
+
+// stop stops the Timer with the error. Calls that returned driver.ErrSkip
+// were not executed, and database/sql retries them another way, so they are
+// discarded.
+func stop(timer mon.Timer, err error) {
+	if err == driver.ErrSkip {
+		timer.Discard()
+		return
+	}
+	if err != nil {
+		err = driverError{err}
+	}
+	timer.Stop(&err)
+}
+
+// conn records the calls made to a connection.
+type conn struct {
+	d *Driver
+	c driver.Conn
+}
+
+var (
+	_ driver.ConnPrepareContext = (*conn)(nil)
+	_ driver.ConnBeginTx        = (*conn)(nil)
+	_ driver.ExecerContext      = (*conn)(nil)
+	_ driver.QueryerContext     = (*conn)(nil)
+	_ driver.Pinger             = (*conn)(nil)
+	_ driver.SessionResetter    = (*conn)(nil)
+	_ driver.NamedValueChecker  = (*conn)(nil)
+	_ driver.Validator          = (*conn)(nil)
+)
+
+func (c *conn) Close() error { return c.c.Close() }
+
+func (c *conn) Prepare(query string) (driver.Stmt, error) {
+	return c.PrepareContext(context.Background(), query)
+}
+
+func (c *conn) PrepareContext(ctx context.Context, query string) (_ driver.Stmt, err error) {
+	label := c.d.queryLabel(ctx, query)
+	timer := c.d.start(PrepareName, label)
+	defer func() { stop(timer, err) }()
+
+	var s driver.Stmt
+	if pc, ok := c.c.(driver.ConnPrepareContext); ok {
+		s, err = pc.PrepareContext(ctx, query)
+	} else {
+		s, err = c.c.Prepare(query)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &stmt{d: c.d, c: c.c, s: s, label: label}, nil
+}
+
+func (c *conn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (c *conn) BeginTx(ctx context.Context, opts driver.TxOptions) (_ driver.Tx, err error) {
+	timer := c.d.start(BeginName)
+	defer func() { stop(timer, err) }()
+
+	var t driver.Tx
+	if bc, ok := c.c.(driver.ConnBeginTx); ok {
+		t, err = bc.BeginTx(ctx, opts)
+	} else if opts.ReadOnly || opts.Isolation != 0 {
+		return nil, errors.New("monsql: driver does not support transaction options")
+	} else {
+		t, err = c.c.Begin()
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &tx{d: c.d, t: t}, nil
+}
+
+func (c *conn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (_ driver.Result, err error) {
+	ec, eok := c.c.(driver.ExecerContext)
+	e, ok := c.c.(driver.Execer)
+	if !eok && !ok {
+		return nil, driver.ErrSkip
+	}
+
+	timer := c.d.start(ExecName, c.d.queryLabel(ctx, query))
+	defer func() { stop(timer, err) }()
+
+	if eok {
+		return ec.ExecContext(ctx, query, args)
+	}
+	values, err := namedValues(args)
+	if err != nil {
+		return nil, err
+	}
+	return e.Exec(query, values)
+}
+
+func (c *conn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (_ driver.Rows, err error) {
+	qc, qok := c.c.(driver.QueryerContext)
+	q, ok := c.c.(driver.Queryer)
+	if !qok && !ok {
+		return nil, driver.ErrSkip
+	}
+
+	label := c.d.queryLabel(ctx, query)
+	timer := c.d.start(QueryName, label)
+	defer func() { stop(timer, err) }()
+
+	var r driver.Rows
+	if qok {
+		r, err = qc.QueryContext(ctx, query, args)
+	} else {
+		var values []driver.Value
+		if values, err = namedValues(args); err == nil {
+			r, err = q.Query(query, values)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return c.d.newRows(r, label), nil
+}
+
+func (c *conn) Ping(ctx context.Context) error {
+	if p, ok := c.c.(driver.Pinger); ok {
+		return p.Ping(ctx)
+	}
+	return nil
+}
+
+func (c *conn) ResetSession(ctx context.Context) error {
+	if sr, ok := c.c.(driver.SessionResetter); ok {
+		return sr.ResetSession(ctx)
+	}
+	return nil
+}
+
+func (c *conn) CheckNamedValue(nv *driver.NamedValue) error {
+	if nvc, ok := c.c.(driver.NamedValueChecker); ok {
+		return nvc.CheckNamedValue(nv)
+	}
+	return driver.ErrSkip
+}
+
+func (c *conn) IsValid() bool {
+	if v, ok := c.c.(driver.Validator); ok {
+		return v.IsValid()
+	}
+	return true
+}
+
+// stmt records the calls made to a prepared statement.
+type stmt struct {
+	d     *Driver
+	c     driver.Conn // the connection the statement was prepared on
+	s     driver.Stmt
+	label mon.Label
+}
+
+var (
+	_ driver.StmtExecContext   = (*stmt)(nil)
+	_ driver.StmtQueryContext  = (*stmt)(nil)
+	_ driver.NamedValueChecker = (*stmt)(nil)
+	_ driver.ColumnConverter   = (*stmt)(nil)
+)
+
+func (s *stmt) Close() error  { return s.s.Close() }
+func (s *stmt) NumInput() int { return s.s.NumInput() }
+
+func (s *stmt) Exec(args []driver.Value) (driver.Result, error) {
+	timer := s.d.start(ExecName, s.label)
+	res, err := s.s.Exec(args)
+	stop(timer, err)
+	return res, err
+}
+
+func (s *stmt) Query(args []driver.Value) (driver.Rows, error) {
+	timer := s.d.start(QueryName, s.label)
+	r, err := s.s.Query(args)
+	stop(timer, err)
+	if err != nil {
+		return nil, err
+	}
+	return s.d.newRows(r, s.label), nil
+}
+
+func (s *stmt) ExecContext(ctx context.Context, args []driver.NamedValue) (_ driver.Result, err error) {
+	timer := s.d.start(ExecName, s.label)
+	defer func() { stop(timer, err) }()
+
+	if sc, ok := s.s.(driver.StmtExecContext); ok {
+		return sc.ExecContext(ctx, args)
+	}
+	values, err := namedValues(args)
+	if err != nil {
+		return nil, err
+	}
+	return s.s.Exec(values)
+}
+
+func (s *stmt) QueryContext(ctx context.Context, args []driver.NamedValue) (_ driver.Rows, err error) {
+	timer := s.d.start(QueryName, s.label)
+	defer func() { stop(timer, err) }()
+
+	var r driver.Rows
+	if sc, ok := s.s.(driver.StmtQueryContext); ok {
+		r, err = sc.QueryContext(ctx, args)
+	} else {
+		var values []driver.Value
+		if values, err = namedValues(args); err == nil {
+			r, err = s.s.Query(values)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return s.d.newRows(r, s.label), nil
+}
+
+// CheckNamedValue checks with the statement or else the connection, like
+// database/sql does. Returning driver.ErrSkip makes database/sql fall back to
+// ColumnConverter.
+func (s *stmt) CheckNamedValue(nv *driver.NamedValue) error {
+	if nvc, ok := s.s.(driver.NamedValueChecker); ok {
+		return nvc.CheckNamedValue(nv)
+	}
+	if nvc, ok := s.c.(driver.NamedValueChecker); ok {
+		return nvc.CheckNamedValue(nv)
+	}
+	return driver.ErrSkip
+}
+
+// ColumnConverter returns the converter of the statement for the argument, or
+// the default converter if it has none.
+func (s *stmt) ColumnConverter(idx int) driver.ValueConverter {
+	if cc, ok := s.s.(driver.ColumnConverter); ok {
+		return cc.ColumnConverter(idx)
+	}
+	return driver.DefaultParameterConverter
+}
+
+// rows records the time spent iterating over the results of a query.
+type rows struct {
+	r     driver.Rows
+	timer mon.Timer
+	err   error
+}
+
+var _ driver.RowsNextResultSet = (*rows)(nil)
+
+// newRows returns rows that record into the state for the label.
+func (d *Driver) newRows(r driver.Rows, label mon.Label) *rows {
+	return &rows{r: r, timer: d.start(RowsName, label)}
+}
+
+func (r *rows) Columns() []string { return r.r.Columns() }
+
+func (r *rows) Next(dest []driver.Value) error {
+	err := r.r.Next(dest)
+	if err != nil && err != io.EOF && r.err == nil {
+		r.err = err
+	}
+	return err
+}
+
+func (r *rows) Close() error {
+	err := r.r.Close()
+	if r.err == nil {
+		r.err = err
+	}
+	stop(r.timer, r.err)
+	return err
+}
+
+func (r *rows) HasNextResultSet() bool {
+	if rs, ok := r.r.(driver.RowsNextResultSet); ok {
+		return rs.HasNextResultSet()
+	}
+	return false
+}
+
+func (r *rows) NextResultSet() error {
+	if rs, ok := r.r.(driver.RowsNextResultSet); ok {
+		return rs.NextResultSet()
+	}
+	return io.EOF
+}
+
+// tx records the calls made to a transaction.
+type tx struct {
+	d *Driver
+	t driver.Tx
+}
+
+func (t *tx) Commit() error {
+	timer := t.d.start(CommitName)
+	err := t.t.Commit()
+	stop(timer, err)
+	return err
+}
+
+func (t *tx) Rollback() error {
+	timer := t.d.start(RollbackName)
+	err := t.t.Rollback()
+	stop(timer, err)
+	return err
+}
+
+// namedValues converts the arguments for drivers that do not support names.
+func namedValues(args []driver.NamedValue) ([]driver.Value, error) {
+	values := make([]driver.Value, len(args))
+	for i, arg := range args {
+		if arg.Name != "" {
+			return nil, errors.New("monsql: driver does not support named arguments")
+		}
+		values[i] = arg.Value
+	}
+	return values, nil
+}
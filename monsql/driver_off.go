@@ -0,0 +1,42 @@
+// +build nomon
+
+// Package monsql records the calls made through database/sql drivers.
+package monsql
+
+import (
+	"context"
+	"database/sql/driver"
+
+	"github.com/zeebo/mon"
+)
+
+// The names calls are recorded under.
+const (
+	PrepareName  = "sql.prepare"
+	ExecName     = "sql.exec"
+	QueryName    = "sql.query"
+	RowsName     = "sql.rows"
+	BeginName    = "sql.begin"
+	CommitName   = "sql.commit"
+	RollbackName = "sql.rollback"
+)
+
+// QueryLabel is the key of the label holding the name of the query.
+const QueryLabel = "query"
+
+// WithName returns the context.
+func WithName(ctx context.Context, name string) context.Context { return ctx }
+
+// Driver is a driver.Driver that records the calls made to Base. With the
+// nomon tag, nothing is recorded.
+type Driver struct {
+	Base      driver.Driver
+	Normalize func(query string) string
+	Registry  *mon.Registry
+}
+
+// Wrap returns base.
+func Wrap(base driver.Driver) driver.Driver { return base }
+
+// Open opens a connection with Base.
+func (d *Driver) Open(dsn string) (driver.Conn, error) { return d.Base.Open(dsn) }
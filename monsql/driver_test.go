@@ -0,0 +1,218 @@
+// +build !nomon
+
+package monsql
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"sync/atomic"
+	"testing"
+
+	"github.com/zeebo/assert"
+	"github.com/zeebo/mon"
+)
+
+// fakeDriver is an in-process driver where every query returns three rows,
+// and where queries and commits starting with "fail" fail.
+type fakeDriver struct{ failCommit bool }
+
+func (d fakeDriver) Open(string) (driver.Conn, error) { return &fakeConn{d}, nil }
+
+type fakeConn struct{ d fakeDriver }
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) { return fakeStmt(query), nil }
+func (c *fakeConn) Close() error                              { return nil }
+func (c *fakeConn) Begin() (driver.Tx, error)                 { return fakeTx(c.d), nil }
+
+type fakeStmt string
+
+func (s fakeStmt) Close() error  { return nil }
+func (s fakeStmt) NumInput() int { return -1 }
+
+func (s fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	if len(s) >= 4 && s[:4] == "fail" {
+		return nil, errors.New("constraint: duplicate key")
+	}
+	return driver.RowsAffected(1), nil
+}
+
+func (s fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	return &fakeRows{left: 3}, nil
+}
+
+type fakeRows struct{ left int }
+
+func (r *fakeRows) Columns() []string { return []string{"n"} }
+func (r *fakeRows) Close() error      { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if r.left == 0 {
+		return io.EOF
+	}
+	dest[0] = int64(r.left)
+	r.left--
+	return nil
+}
+
+type fakeTx fakeDriver
+
+func (t fakeTx) Commit() error {
+	if t.failCommit {
+		return driver.ErrBadConn
+	}
+	return nil
+}
+
+func (t fakeTx) Rollback() error { return nil }
+
+// connector opens connections with a driver.
+type connector struct{ d driver.Driver }
+
+func (c connector) Connect(context.Context) (driver.Conn, error) { return c.d.Open("") }
+func (c connector) Driver() driver.Driver                        { return c.d }
+
+func errorCount(st *mon.State, kind string) int64 {
+	if p := st.Errors().Lookup(kind); p != nil {
+		return atomic.LoadInt64((*int64)(p))
+	}
+	return 0
+}
+
+func TestDriver(t *testing.T) {
+	var reg mon.Registry
+	db := sql.OpenDB(connector{&Driver{Base: fakeDriver{}, Registry: &reg}})
+	defer func() { _ = db.Close() }()
+
+	_, err := db.Exec("insert into t values (1)")
+	assert.NoError(t, err)
+	_, err = db.Exec("insert into t values (2)")
+	assert.NoError(t, err)
+	_, err = db.Exec("fail insert")
+	assert.Error(t, err)
+
+	label := mon.Label{Key: QueryLabel, Value: "insert into t values (?)"}
+	assert.Equal(t, reg.LookupLabeledState(ExecName, label).Total(), 2)
+	assert.Equal(t, reg.LookupLabeledState(PrepareName, label).Total(), 2)
+	assert.Equal(t, errorCount(reg.LookupLabeledState(ExecName,
+		mon.Label{Key: QueryLabel, Value: "fail insert"}), "constraint"), 1)
+
+	rows, err := db.QueryContext(WithName(context.Background(), "count"), "select n from t where x = 5")
+	assert.NoError(t, err)
+	count := 0
+	for rows.Next() {
+		count++
+	}
+	assert.NoError(t, rows.Close())
+	assert.Equal(t, count, 3)
+
+	label = mon.Label{Key: QueryLabel, Value: "count"}
+	assert.Equal(t, reg.LookupLabeledState(QueryName, label).Total(), 1)
+	assert.Equal(t, reg.LookupLabeledState(RowsName, label).Total(), 1)
+
+	tx, err := db.Begin()
+	assert.NoError(t, err)
+	assert.NoError(t, tx.Rollback())
+	assert.Equal(t, reg.LookupState(BeginName).Total(), 1)
+	assert.Equal(t, reg.LookupState(RollbackName).Total(), 1)
+}
+
+func TestDriverErrorKinds(t *testing.T) {
+	var reg mon.Registry
+	db := sql.OpenDB(connector{&Driver{Base: fakeDriver{failCommit: true}, Registry: &reg}})
+	defer func() { _ = db.Close() }()
+
+	tx, err := db.Begin()
+	assert.NoError(t, err)
+	assert.Error(t, tx.Commit())
+	assert.Equal(t, errorCount(reg.LookupState(CommitName), "bad_conn"), 1)
+}
+
+// skipDriver opens connections whose fast paths always return driver.ErrSkip,
+// like drivers that cannot interpolate arguments.
+type skipDriver struct{ fakeDriver }
+
+func (d skipDriver) Open(string) (driver.Conn, error) { return &skipConn{fakeConn{d.fakeDriver}}, nil }
+
+type skipConn struct{ fakeConn }
+
+func (c *skipConn) ExecContext(context.Context, string, []driver.NamedValue) (driver.Result, error) {
+	return nil, driver.ErrSkip
+}
+
+func (c *skipConn) QueryContext(context.Context, string, []driver.NamedValue) (driver.Rows, error) {
+	return nil, driver.ErrSkip
+}
+
+func TestDriverSkip(t *testing.T) {
+	var reg mon.Registry
+	db := sql.OpenDB(connector{&Driver{Base: skipDriver{}, Registry: &reg}})
+	defer func() { _ = db.Close() }()
+
+	_, err := db.Exec("insert into t values (?)", 1)
+	assert.NoError(t, err)
+	rows, err := db.Query("select n from t")
+	assert.NoError(t, err)
+	assert.NoError(t, rows.Close())
+
+	label := mon.Label{Key: QueryLabel, Value: "insert into t values (?)"}
+	exec := reg.LookupLabeledState(ExecName, label)
+	assert.Equal(t, exec.Total(), 1)
+	assert.Equal(t, exec.Current(), 0)
+	assert.Equal(t, errorCount(exec, "skip"), 0)
+	assert.Equal(t, reg.LookupLabeledState(PrepareName, label).Total(), 1)
+
+	label = mon.Label{Key: QueryLabel, Value: "select n from t"}
+	assert.Equal(t, reg.LookupLabeledState(QueryName, label).Total(), 1)
+}
+
+// celsius is an argument type only checkerConn knows how to convert.
+type celsius struct{ degrees float64 }
+
+// checkerDriver opens connections that convert celsius arguments, report
+// themselves invalid, and prepare statements with a ColumnConverter.
+type checkerDriver struct{ fakeDriver }
+
+func (d checkerDriver) Open(string) (driver.Conn, error) {
+	return &checkerConn{fakeConn{d.fakeDriver}}, nil
+}
+
+type checkerConn struct{ fakeConn }
+
+func (c *checkerConn) IsValid() bool { return false }
+
+func (c *checkerConn) Prepare(query string) (driver.Stmt, error) {
+	return converterStmt{fakeStmt(query)}, nil
+}
+
+func (c *checkerConn) CheckNamedValue(nv *driver.NamedValue) error {
+	if v, ok := nv.Value.(celsius); ok {
+		nv.Value = v.degrees
+		return nil
+	}
+	return driver.ErrSkip
+}
+
+type converterStmt struct{ fakeStmt }
+
+func (s converterStmt) ColumnConverter(int) driver.ValueConverter { return driver.Bool }
+
+func TestDriverOptionalInterfaces(t *testing.T) {
+	var reg mon.Registry
+	d := &Driver{Base: checkerDriver{}, Registry: &reg}
+	db := sql.OpenDB(connector{d})
+	defer func() { _ = db.Close() }()
+
+	_, err := db.Exec("insert into t values (?)", celsius{21.5})
+	assert.NoError(t, err)
+
+	c, err := d.Open("")
+	assert.NoError(t, err)
+	assert.That(t, !c.(driver.Validator).IsValid())
+
+	s, err := c.Prepare("select n from t")
+	assert.NoError(t, err)
+	assert.Equal(t, s.(driver.ColumnConverter).ColumnConverter(0), driver.Bool)
+}
@@ -0,0 +1,10 @@
+module github.com/zeebo/mon/monsql
+
+go 1.12
+
+require (
+	github.com/zeebo/assert v1.1.0
+	github.com/zeebo/mon v0.0.0-20190829025240-97443e9d2649
+)
+
+replace github.com/zeebo/mon => ../
@@ -0,0 +1,75 @@
+package monsql
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// maxNormalized is the longest normalized query, in bytes.
+const maxNormalized = 100
+
+// Normalize returns a name for the query that does not depend on the values in
+// it. Quoted strings and numbers are replaced with ?, runs of whitespace are
+// replaced with a single space, and it is truncated to at most 100 bytes
+// without splitting a character.
+func Normalize(query string) string {
+	var b strings.Builder
+	prev, space := byte(0), false
+
+	i := 0
+	for ; i < len(query) && b.Len() < maxNormalized; i++ {
+		ch := query[i]
+		switch {
+		case ch == ' ' || ch == '\t' || ch == '\n' || ch == '\r':
+			space = prev != 0
+			continue
+
+		case ch == '\'':
+			// skip to the closing quote, where a doubled quote is an escape.
+			for i++; i < len(query); i++ {
+				if query[i] != '\'' {
+					continue
+				} else if i+1 < len(query) && query[i+1] == '\'' {
+					i++
+					continue
+				}
+				break
+			}
+			ch = '?'
+
+		case isDigit(ch) && (space || !isIdent(prev) && prev != '$'):
+			for i+1 < len(query) && (isDigit(query[i+1]) || query[i+1] == '.') {
+				i++
+			}
+			ch = '?'
+		}
+
+		if space {
+			b.WriteByte(' ')
+			space = false
+		}
+		b.WriteByte(ch)
+		prev = ch
+	}
+
+	// drop the start of a character split by the truncation.
+	out := b.String()
+	if i < len(query) && !utf8.RuneStart(query[i]) {
+		n := len(out)
+		for n > 0 && !utf8.RuneStart(out[n-1]) {
+			n--
+		}
+		if n > 0 && out[n-1] >= utf8.RuneSelf {
+			n--
+		}
+		out = out[:n]
+	}
+	return out
+}
+
+func isDigit(ch byte) bool { return '0' <= ch && ch <= '9' }
+
+// isIdent returns true if the byte can be part of an identifier.
+func isIdent(ch byte) bool {
+	return ch == '_' || isDigit(ch) || 'a' <= ch && ch <= 'z' || 'A' <= ch && ch <= 'Z'
+}
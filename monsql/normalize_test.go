@@ -0,0 +1,22 @@
+package monsql
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/zeebo/assert"
+)
+
+func TestNormalize(t *testing.T) {
+	for _, tc := range []struct{ in, out string }{
+		{"SELECT 1", "SELECT ?"},
+		{"  select *\n\tfrom t1  where id = 42 ", "select * from t1 where id = ?"},
+		{"insert into t values ('it''s', 1.5, $1, ?)", "insert into t values (?, ?, $1, ?)"},
+		{"update t2 set x=-3", "update t2 set x=-?"},
+		{strings.Repeat("a", 200), strings.Repeat("a", 100)},
+		{strings.Repeat("a", 99) + "é", strings.Repeat("a", 99)},
+		{strings.Repeat("a", 98) + "日本", strings.Repeat("a", 98)},
+	} {
+		assert.Equal(t, Normalize(tc.in), tc.out)
+	}
+}
@@ -0,0 +1,191 @@
+package mon
+
+import (
+	"context"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// useAPI calls every exported symbol so that it is type checked with and
+// without the nomon tag.
+func useAPI() {
+	var err error
+	ctx := context.Background()
+
+	CapturePanics(false)
+	TrackWindows(false)
+	SetErrorKindLimit(0)
+	Default.SetErrorKindLimit(0)
+	RegisterClassifier(func(err error) (string, bool) { return "", false })
+
+	var th Thunk
+	th.Start().Stop(&err)
+	nth := NamedThunk{Name: "name"}
+	nth.Start().Stop(&err)
+	Start().Stop(&err)
+	StartNamed("name").StopExemplar(&err, "id")
+	StartNamed("name").Discard()
+	StartLabeled("name", Label{Key: "key", Value: "value"}).Stop(nil)
+	ctx, timer := StartContext(ctx)
+	timer.Stop(nil)
+	_, timer = StartNamedContext(ctx, "name")
+	timer.Stop(nil)
+
+	for _, reg := range []*Registry{Default, new(Registry)} {
+		reg.Start().Stop(nil)
+		reg.StartNamed("name").Stop(nil)
+		reg.StartLabeled("name").Stop(nil)
+		_, timer = reg.StartContext(ctx)
+		timer.Stop(nil)
+		_, timer = reg.StartNamedContext(ctx, "name")
+		timer.Stop(nil)
+
+		reg.GetCounter("counter").Inc()
+		reg.GetGauge("gauge").Set(1)
+		reg.GetGaugeFunc("gauge", func() float64 { return 1 })
+		reg.GetMeter("meter").Mark(1)
+		reg.GetDistribution("dist", UnitBytes).Observe(1)
+		reg.Observe("dist", 1)
+		_ = reg.GetState("name")
+		_ = reg.LookupState("name")
+		_ = reg.LookupKey("name")
+		_ = reg.GetLabeledState("name")
+		_ = reg.LookupLabeledState("name")
+		_ = reg.LookupEdge("parent", "child")
+		reg.Edges(func(parent, child string, st *State) bool { return true })
+		reg.Times(func(name string, st *State) bool { return true })
+		reg.Collect(func(name string, st *State) bool { return true })
+		_ = reg.EvictIdle(1)
+	}
+
+	c := GetCounter("counter")
+	c.Inc()
+	c.Add(1)
+	_ = c.Value()
+
+	g := GetGauge("gauge")
+	g.Set(1)
+	g.Add(1)
+	_ = g.Value()
+	_ = GetGaugeFunc("gauge", func() float64 { return 1 }).Value()
+
+	m := GetMeter("meter")
+	m.Mark(1)
+	_, _ = m.Count(), m.Rate()
+
+	d := GetDistribution("dist", UnitRatio)
+	d.Observe(1)
+	_, _ = d.Histogram(), d.Unit()
+	Observe("dist", 1)
+
+	st := GetState("name")
+	_, _, _ = LookupState("name"), GetLabeledState("name"), LookupLabeledState("name")
+	_ = LookupKey("name")
+	_, _, _, _ = st.Kind().String(), st.Counter(), st.Gauge(), st.Meter()
+	_, _, _, _ = st.Distribution(), st.Unit().String(), st.Unit().Format(1), st.Name()
+	_, _, _, _ = st.Labels(), st.Histogram(), st.Errors(), st.Window(time.Minute)
+	_ = st.WindowErrors(time.Minute)
+	st.Record(time.Second, "")
+	_ = st.RecordN(time.Second, 2, "")
+	_ = st.RecordBatch([]time.Duration{time.Second}, []int64{2}, "")
+	st.MergeHistogram(st.Histogram())
+	_, _, _, _ = st.Total(), st.Current(), st.Peak(), st.Quantile(0.5)
+	_, _, _, _ = st.Sum(), st.ExactSum(), st.Min(), st.Max()
+	_, _ = st.Average()
+	_, _, _ = st.Variance()
+
+	_ = LookupEdge("parent", "child")
+	Edges(func(parent, child string, st *State) bool { return true })
+	Times(func(name string, st *State) bool { return true })
+	Collect(func(name string, st *State) bool { return true })
+	_ = EvictIdle(1)
+
+	_, _ = st.Folded(), OverflowName+OverflowKind
+	SetNameLimit(0)
+	SetSampleRate(1)
+	SetNameSampleRate("name", 1)
+	Default.SetSampleRate(1)
+	Default.SetNameSampleRate("name", 1)
+	_ = st.SampleRate()
+	Default.SetNameLimit(0)
+
+	snap := TakeSnapshot()
+	delta := Default.Snapshot().Sub(snap)
+	if ss := delta.Lookup("name"); ss != nil {
+		_, _, _, _, _ = ss.Key, ss.Name, ss.Labels, ss.Kind, ss.Unit
+		_, _, _, _, _, _, _ = ss.Count, ss.Value, ss.Rate, ss.Current, ss.Peak, ss.Folded, ss.Errors
+		_, _ = ss.Histogram, ss.Values
+	}
+	_, _, _ = delta.Time, delta.Elapsed, delta.States
+	_ = GetCursor("consumer").Read()
+	_ = Default.GetCursor("consumer").Read()
+	RemoveCursor("consumer")
+	Default.RemoveCursor("consumer")
+
+	rc := RuntimeCollector{Registry: Default}
+	rc.Read()
+	_ = []string{RuntimePrefix, RuntimeGCPauses, RuntimeGCCount, RuntimeGoroutines, RuntimeHeapAlloc,
+		RuntimeHeapSys, RuntimeHeapIdle, RuntimeHeapObjects, RuntimeSchedLatency}
+	if false {
+		rc.Run(ctx, 0)
+	}
+
+	slow := Threshold{Duration: time.Second, Quantile: 0.999, Stack: true}
+	SetSlowThreshold(slow)
+	SetNameSlowThreshold("name", slow)
+	RegisterSlowHook(func(call SlowCall) {
+		_, _, _, _ = call.Name, call.Labels, call.Start, call.Duration
+		_, _, _ = call.Kind, call.Err, call.Stack
+	})
+	_ = SlowCalls()
+	Default.SetSlowThreshold(slow)
+	Default.SetNameSlowThreshold("name", slow)
+	Default.RegisterSlowHook(func(SlowCall) {})
+	_ = Default.SlowCalls()
+
+	objective := Objective{Name: "name", Latency: time.Second, LatencyTarget: 0.99, MaxErrorRatio: 0.001,
+		Good: func(kind string) bool { return false }}
+	SetObjective(objective)
+	Default.SetObjective(objective)
+	for _, status := range append(Objectives(), Default.Objectives()...) {
+		_, _, _, _ = status.Objective, status.Window, status.Total, status.Met()
+		_, _, _, _ = status.LatencyCompliance, status.ErrorRatio, status.LatencyBurn, status.ErrorBurn
+	}
+	RemoveObjective("name")
+	Default.RemoveObjective("name")
+
+	_ = []Kind{KindTimer, KindCounter, KindGauge, KindMeter, KindDistribution}
+	_ = []Unit{UnitNone, UnitNanoseconds, UnitBytes, UnitRatio}
+}
+
+// TestBuildTags vets the root module and every integration module with and
+// without the nomon tag.
+func TestBuildTags(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping build tag check in short mode")
+	}
+	gobin, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go tool not found")
+	}
+	mods, err := filepath.Glob(filepath.Join("mon*", "go.mod"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dirs := []string{"."}
+	for _, mod := range mods {
+		dirs = append(dirs, filepath.Dir(mod))
+	}
+	for _, dir := range dirs {
+		for _, tags := range []string{"", "nomon"} {
+			cmd := exec.Command(gobin, "vet", "-tags", tags, "./...")
+			cmd.Dir = dir
+			if out, err := cmd.CombinedOutput(); err != nil {
+				t.Fatalf("dir=%q tags=%q: %v\n%s", dir, tags, err, out)
+			}
+		}
+	}
+}
@@ -0,0 +1,80 @@
+// +build !nomon
+
+package mon
+
+import (
+	"fmt"
+	"math"
+	"math/bits"
+	"sync/atomic"
+	"time"
+
+	"github.com/zeebo/mon/inthist"
+)
+
+// Record records a call that took the duration and failed with the error kind,
+// if it is not empty, as if it had been timed, including reporting it if it
+// was slow. It is for durations measured somewhere else, like a span read from
+// a message header. Negative durations are recorded as zero.
+func (s *State) Record(d time.Duration, kind string) { s.RecordN(d, 1, kind) }
+
+// RecordN records n calls that each took the duration and failed with the error
+// kind, if it is not empty, and reports if it did. A slow duration is reported
+// once. Nothing is recorded if the state is not a timer, if n is not positive,
+// if n is more than math.MaxUint32 because histograms count each duration in 32
+// bits, or if the sum of the n durations does not fit in an int64.
+func (s *State) RecordN(d time.Duration, n int64, kind string) bool {
+	if s.kind != KindTimer {
+		return false
+	}
+	v := int64(d)
+	if v < 0 {
+		v = 0
+	}
+	if hi, lo := bits.Mul64(uint64(v), uint64(n)); n <= 0 || n > math.MaxUint32 || hi != 0 || lo > math.MaxInt64 {
+		return false
+	}
+	s.observe(v, n, kind, "")
+	if r := s.registry(); r != nil {
+		s.sampleRate(r) // resolves the slow threshold
+		s.reportSlow(v, kind, nil, nil)
+	}
+	return true
+}
+
+// RecordBatch records a batch of durations, like latencies parsed from a log,
+// as calls that failed with the error kind, if it is not empty. If weights is
+// not nil, each duration is recorded as the number of calls in weights at the
+// same index, and it panics unless the lengths match. Durations are recorded
+// as by RecordN, which skips invalid weights, and it returns the number of
+// calls recorded.
+func (s *State) RecordBatch(durations []time.Duration, weights []int64, kind string) (calls int64) {
+	if weights != nil && len(weights) != len(durations) {
+		panic(fmt.Sprintf("mon: %d weights for %d durations", len(weights), len(durations)))
+	}
+	for i, d := range durations {
+		n := int64(1)
+		if weights != nil {
+			n = weights[i]
+		}
+		if s.RecordN(d, n, kind) {
+			calls += n
+		}
+	}
+	return calls
+}
+
+// MergeHistogram records every duration in the histogram, like a batch of
+// latencies parsed from a log, as calls that did not fail. If TrackWindows is
+// enabled, they are all recorded into the current minute. Because a histogram
+// does not keep the individual durations, none of them are reported as slow.
+// It does nothing if the histogram is nil or the state is not a timer.
+func (s *State) MergeHistogram(his *inthist.Histogram) {
+	if his == nil || s.kind != KindTimer || his.Total() == 0 {
+		return
+	}
+	s.timer.his.Merge(his)
+	if atomic.LoadUint32(&trackWindows) != 0 {
+		s.loadWindows().slot(nanotime()).his.Merge(his)
+	}
+}
@@ -0,0 +1,23 @@
+// +build nomon
+
+package mon
+
+import (
+	"time"
+
+	"github.com/zeebo/mon/inthist"
+)
+
+// Record does nothing.
+func (s *State) Record(d time.Duration, kind string) {}
+
+// RecordN does nothing.
+func (s *State) RecordN(d time.Duration, n int64, kind string) bool { return false }
+
+// RecordBatch does nothing.
+func (s *State) RecordBatch(durations []time.Duration, weights []int64, kind string) int64 {
+	return 0
+}
+
+// MergeHistogram does nothing.
+func (s *State) MergeHistogram(his *inthist.Histogram) {}
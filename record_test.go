@@ -0,0 +1,102 @@
+// +build !nomon
+
+package mon
+
+import (
+	"testing"
+	"time"
+
+	"github.com/zeebo/assert"
+	"github.com/zeebo/mon/inthist"
+)
+
+func TestRecord(t *testing.T) {
+	t.Run("Durations", func(t *testing.T) {
+		var reg Registry
+		st := reg.GetState("record")
+		st.Record(time.Second, "")
+		st.Record(-time.Second, "")
+		assert.That(t, st.RecordN(time.Minute, 3, "timeout"))
+		assert.That(t, !st.RecordN(time.Hour, 0, "ignored"))
+		assert.That(t, !st.RecordN(time.Second, 1<<32, "ignored"))
+		assert.That(t, !st.RecordN(time.Hour, 1<<30, "ignored"))
+		assert.That(t, !(*State)(reg.GetCounter("counter")).RecordN(time.Second, 1, ""))
+
+		assert.Equal(t, st.Total(), 5)
+		assert.Equal(t, st.Current(), 0)
+		assert.Equal(t, st.Min(), 0)
+		assert.Equal(t, st.Max(), int64(time.Minute))
+		assert.Equal(t, st.ExactSum(), int64(time.Second+3*time.Minute))
+		assert.DeepEqual(t, errorCounts(st), map[string]int64{"timeout": 3})
+	})
+
+	t.Run("Batch", func(t *testing.T) {
+		var reg Registry
+		st := reg.GetState("batch")
+		calls := st.RecordBatch([]time.Duration{time.Second, time.Minute, time.Hour}, []int64{2, 3, 0}, "")
+		assert.Equal(t, calls, 5)
+		assert.Equal(t, st.RecordBatch([]time.Duration{time.Second, time.Second}, nil, "timeout"), 2)
+
+		assert.Equal(t, st.Total(), 7)
+		assert.Equal(t, st.ExactSum(), int64(4*time.Second+3*time.Minute))
+		assert.DeepEqual(t, errorCounts(st), map[string]int64{"timeout": 2})
+		assert.That(t, panics(func() { st.RecordBatch(make([]time.Duration, 2), make([]int64, 1), "") }))
+	})
+
+	t.Run("Slow", func(t *testing.T) {
+		var reg Registry
+		reg.SetSlowThreshold(Threshold{Duration: time.Minute})
+		st := reg.GetState("slow")
+		st.Record(time.Second, "")
+		st.RecordN(time.Hour, 2, "timeout")
+
+		calls := reg.SlowCalls()
+		assert.Equal(t, len(calls), 1)
+		assert.Equal(t, calls[0].Name, "slow")
+		assert.Equal(t, calls[0].Duration, time.Hour)
+		assert.Equal(t, calls[0].Kind, "timeout")
+	})
+
+	t.Run("MergeHistogram", func(t *testing.T) {
+		var reg Registry
+		st := reg.GetState("merge")
+
+		var his inthist.Histogram
+		for i := int64(1); i <= 100; i++ {
+			his.Observe(i * int64(time.Millisecond))
+		}
+		st.MergeHistogram(&his)
+		st.MergeHistogram(new(inthist.Histogram))
+		st.MergeHistogram(nil)
+
+		assert.Equal(t, st.Total(), 100)
+		assert.Equal(t, st.Max(), int64(100*time.Millisecond))
+		assert.Equal(t, st.ExactSum(), his.ExactSum())
+	})
+
+	t.Run("Windows", func(t *testing.T) {
+		TrackWindows(true)
+		defer TrackWindows(false)
+		defer readNextMinute()()
+
+		var reg Registry
+		st := reg.GetState("windows")
+		st.RecordN(time.Second, 2, "timeout")
+
+		var his inthist.Histogram
+		his.ObserveN(int64(time.Second), 3)
+		st.MergeHistogram(&his)
+
+		assert.Equal(t, st.Window(time.Minute).Total(), 5)
+		assert.DeepEqual(t, st.WindowErrors(time.Minute), map[string]int64{"timeout": 2})
+	})
+}
+
+// errorCounts returns the count of every error kind recorded by the state.
+func errorCounts(st *State) map[string]int64 {
+	counts := make(map[string]int64)
+	for iter := st.Errors().Iterator(); iter.Next(); {
+		counts[iter.Key()] = *(*int64)(iter.Value())
+	}
+	return counts
+}
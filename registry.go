@@ -0,0 +1,187 @@
+// +build !nomon
+
+package mon
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"unsafe"
+
+	"github.com/zeebo/mon/internal/lfht"
+	"github.com/zeebo/swaparoo"
+)
+
+// Registry keeps track of the states for a set of names and the edges between
+// them. Registries are independent, so a library or test can keep its metrics
+// separate from the rest of the program. Each name is used by states of a single
+// Kind, and getting a state for a name with a different Kind panics. The zero
+// value is ready to use, and a Registry must not be copied after first use.
+type Registry struct {
+	gen        uint64            // incremented when cached states become invalid.
+	limit      int64             // approximate maximum number of names, or 0 for no limit.
+	names      [2]int64          // approximate number of names in each table when limited.
+	edgeNames  [2]int64          // approximate number of edges in each table when limited.
+	kindLimit  int64             // approximate maximum number of error kinds per state, or 0.
+	removed    [2]int64          // entries of removed names left in each table, protected by mu.
+	rate       uint32            // default sample rate for timers.
+	confVer    uint32            // incremented when sample rates or slow thresholds change.
+	rates      atomic.Value      // map[string]uint32 of sample rates by name.
+	slow       atomic.Value      // *slowConfig of thresholds and hooks for slow calls.
+	slowLog    slowRing          // the most recent slow calls.
+	objectives atomic.Value      // map[string]Objective of objectives by name.
+	mu         sync.Mutex        // protects concurrent Collect calls.
+	states     [2]lfht.Table     // states maps names to State pointers.
+	edges      [2]lfht.Table     // edges maps parent/child names to State pointers.
+	shared     [2]unsafe.Pointer // *lfht.Table of the parts of states and edges kept across Collect.
+	cursors    lfht.Table        // cursors maps consumer names to Cursor pointers.
+	tracker    swaparoo.Tracker  // keeps track of which state is valid.
+}
+
+// Default is the Registry used by the package level functions.
+var Default = new(Registry)
+
+// Times calls the callback with all of the states in the Default registry.
+func Times(cb func(string, *State) bool) { Default.Times(cb) }
+
+// Collect consumes all of the states in the Default registry. See Registry.Collect.
+func Collect(cb func(string, *State) bool) { Default.Collect(cb) }
+
+// GetState returns the current state for some name in the Default registry,
+// allocating a new one if necessary.
+func GetState(name string) *State { return Default.GetState(name) }
+
+// LookupState returns the current state for some name in the Default registry,
+// returning nil if none exists.
+func LookupState(name string) *State { return Default.LookupState(name) }
+
+// LookupKey returns the current state stored under a key passed to Times in
+// the Default registry, returning nil if none exists. See Registry.LookupKey.
+func LookupKey(key string) *State { return Default.LookupKey(key) }
+
+// Times calls the callback with all of the histograms that have been captured,
+// along with the keys they are stored under. See LookupKey for the keys.
+func (r *Registry) Times(cb func(string, *State) bool) {
+	token := r.tracker.Acquire()
+	for iter := r.states[token.Gen()%2].Iterator(); iter.Next(); {
+		if !cb(iter.Key(), (*State)(iter.Value())) {
+			goto done
+		}
+	}
+done:
+	token.Release()
+}
+
+// Collect consumes all of the histograms that have been captures and calls
+// the callback for each one.
+func (r *Registry) Collect(cb func(string, *State) bool) {
+	r.mu.Lock()
+	// the shared parts of states are pruned against the current generation,
+	// which can not change while the mutex is held.
+	now, token := nanotime(), r.tracker.Acquire()
+	prevShared := pruneShared(&r.shared[0], &r.states[token.Gen()%2], now)
+	prevEdgeShared := pruneShared(&r.shared[1], &r.edges[token.Gen()%2], now)
+	token.Release()
+	gen := r.tracker.Increment().Wait()
+	atomic.AddUint64(&r.gen, 1)
+	keepShared(&r.shared[0], prevShared, now)
+	keepShared(&r.shared[1], prevEdgeShared, now)
+
+	for iter := r.states[gen%2].Iterator(); iter.Next(); {
+		if !cb(iter.Key(), (*State)(iter.Value())) {
+			goto done
+		}
+	}
+done:
+	// gauges describe the present rather than an interval, so they are
+	// carried forward into the next generation. if a concurrent GetGauge
+	// already created the gauge there, its value and callback are copied
+	// into that one unless it has its own.
+	for iter := r.states[gen%2].Iterator(); iter.Next(); {
+		if state := iter.Value(); (*State)(state).kind == KindGauge {
+			got := r.states[(gen+1)%2].Upsert(iter.Key(), func() unsafe.Pointer { return state })
+			if got == state {
+				atomic.AddInt64(&r.names[(gen+1)%2], 1)
+			} else {
+				(*State)(got).carryGauge((*State)(state))
+			}
+		}
+	}
+	r.states[gen%2] = lfht.Table{}
+	r.edges[gen%2] = lfht.Table{}
+	r.removed[gen%2] = 0
+	atomic.StoreInt64(&r.names[gen%2], 0)
+	atomic.StoreInt64(&r.edgeNames[gen%2], 0)
+	r.mu.Unlock()
+}
+
+// GetState returns the current state for some name, allocating a new one if necessary.
+func (r *Registry) GetState(name string) *State {
+	return r.getStateWith(name, KindTimer, newState)
+}
+
+// getStateWith returns the current state for some name, allocating a new one with
+// the constructor if necessary. If there are too many names, it returns the
+// overflow state for the kind instead. It panics if the state for the name is
+// of a different kind.
+func (r *Registry) getStateWith(name string, kind Kind, fn func(string) unsafe.Pointer) *State {
+	token := r.tracker.Acquire()
+	gen := token.Gen()
+	key, state := nameKey(name), (*State)(nil)
+	switch {
+	case !r.admit(&r.states[gen%2], &r.names[gen%2], key):
+		key, state = overflowNames[kind], r.overflow(gen, kind, fn)
+	case key == name:
+		state = (*State)(r.states[gen%2].UpsertKey(key, fn))
+	default:
+		state = (*State)(r.states[gen%2].Upsert(key, func() unsafe.Pointer { return fn(name) }))
+	}
+	r.found(state, &r.shared[0], key)
+	token.Release()
+	return state.checkKind(kind)
+}
+
+// found prepares a state stored under the key before it is returned, recording
+// the registry it belongs to and attaching the part of it kept across Collect.
+// It must be called while holding a tracker token.
+func (r *Registry) found(s *State, shared *unsafe.Pointer, key string) {
+	s.setRegistry(r)
+	s.attachShared(shared, key)
+}
+
+// setRegistry records the registry the state belongs to if it has not been.
+func (s *State) setRegistry(r *Registry) {
+	if atomic.LoadPointer(&s.reg) == nil {
+		atomic.CompareAndSwapPointer(&s.reg, nil, unsafe.Pointer(r))
+	}
+}
+
+// registry returns the registry the state belongs to, or nil if it is not
+// known yet.
+func (s *State) registry() *Registry { return (*Registry)(atomic.LoadPointer(&s.reg)) }
+
+// checkKind returns the state, panicking if it is not of the kind.
+func (s *State) checkKind(kind Kind) *State {
+	if s.kind != kind {
+		panic(fmt.Sprintf("mon: %q is a %s, not a %s", s.name, s.kind, kind))
+	}
+	return s
+}
+
+// generation returns a value that changes whenever states previously returned
+// by the registry may no longer be current.
+func (r *Registry) generation() uint64 { return atomic.LoadUint64(&r.gen) }
+
+// LookupState returns the current state for some name, returning nil if none exists.
+func (r *Registry) LookupState(name string) *State { return r.LookupKey(nameKey(name)) }
+
+// LookupKey returns the current state stored under a key passed to Times,
+// returning nil if none exists. Keys are the names of states without labels,
+// with any braces and backslashes escaped by a backslash, and are followed by
+// the labels in braces for states with them, like name{key="value"}.
+func (r *Registry) LookupKey(key string) *State {
+	token := r.tracker.Acquire()
+	state := (*State)(r.states[token.Gen()%2].Lookup(key))
+	token.Release()
+	return state
+}
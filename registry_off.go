@@ -0,0 +1,71 @@
+// +build nomon
+
+package mon
+
+// Registry keeps track of the states for a set of names and the edges between
+// them. With the nomon tag, nothing is kept.
+type Registry struct{}
+
+// Default is the Registry used by the package level functions.
+var Default = new(Registry)
+
+// Times calls the callback with all of the states in the Default registry.
+func Times(cb func(string, *State) bool) {}
+
+// Collect consumes all of the states in the Default registry.
+func Collect(cb func(string, *State) bool) {}
+
+// GetState returns the current state for some name in the Default registry.
+func GetState(name string) *State { return nil }
+
+// LookupState returns the current state for some name in the Default registry.
+func LookupState(name string) *State { return nil }
+
+// LookupKey returns the current state stored under a key passed to Times in
+// the Default registry.
+func LookupKey(key string) *State { return nil }
+
+// Times calls the callback with all of the histograms that have been captured.
+func (r *Registry) Times(cb func(string, *State) bool) {}
+
+// Collect consumes all of the histograms that have been captures and calls
+// the callback for each one.
+func (r *Registry) Collect(cb func(string, *State) bool) {}
+
+// GetState returns the current state for some name.
+func (r *Registry) GetState(name string) *State { return nil }
+
+// LookupState returns the current state for some name.
+func (r *Registry) LookupState(name string) *State { return nil }
+
+// LookupKey returns the current state stored under a key passed to Times.
+func (r *Registry) LookupKey(key string) *State { return nil }
+
+// EvictIdle removes idle states and edges from the Default registry.
+func EvictIdle(intervals int) int { return 0 }
+
+// EvictIdle removes the states and edges that have not recorded anything
+// during the last intervals calls to EvictIdle.
+func (r *Registry) EvictIdle(intervals int) int { return 0 }
+
+// OverflowName is the name of the state that timers for new names are folded
+// into once a registry has too many names.
+const OverflowName = "mon.overflow"
+
+// SetNameLimit sets the name limit of the Default registry.
+func SetNameLimit(n int) {}
+
+// SetNameLimit sets an approximate limit on the number of distinct names.
+func (r *Registry) SetNameLimit(n int) {}
+
+// SetSampleRate sets the sample rate of the Default registry.
+func SetSampleRate(n int) {}
+
+// SetNameSampleRate sets the sample rate for a name in the Default registry.
+func SetNameSampleRate(name string, n int) {}
+
+// SetSampleRate sets the rate timers are sampled at.
+func (r *Registry) SetSampleRate(n int) {}
+
+// SetNameSampleRate sets the sample rate for timers with the name.
+func (r *Registry) SetNameSampleRate(name string, n int) {}
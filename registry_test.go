@@ -0,0 +1,36 @@
+// +build !nomon
+
+package mon
+
+import (
+	"context"
+	"testing"
+
+	"github.com/zeebo/assert"
+)
+
+func TestRegistry(t *testing.T) {
+	var r1, r2 Registry
+
+	r1.StartNamed("foo").Stop(nil)
+	r1.StartNamed("foo").Stop(nil)
+	r2.StartNamed("foo").Stop(nil)
+	_, timer := r2.StartNamedContext(context.Background(), "bar")
+	timer.Stop(nil)
+
+	assert.Equal(t, r1.LookupState("foo").Total(), 2)
+	assert.Equal(t, r2.LookupState("foo").Total(), 1)
+	assert.Nil(t, r1.LookupState("bar"))
+	assert.Nil(t, LookupState("foo"))
+
+	var names []string
+	r2.Collect(func(name string, st *State) bool {
+		names = append(names, name)
+		return true
+	})
+	assert.Equal(t, len(names), 2)
+	assert.Nil(t, r2.LookupState("foo"))
+	assert.Equal(t, r1.LookupState("foo").Total(), 2)
+
+	r1.Collect(func(string, *State) bool { return true })
+}
@@ -0,0 +1,93 @@
+// +build !nomon
+
+package mon
+
+import (
+	"context"
+	"runtime"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+// RuntimePrefix is the prefix of the names a RuntimeCollector records under.
+// The names are not reserved, but like any name used for two kinds of states,
+// using one of them for a state of another kind makes Read panic.
+const RuntimePrefix = "go."
+
+// The names a RuntimeCollector records under. Pauses and scheduler latencies
+// are timers, the gc count is a counter, and the rest are gauges.
+const (
+	RuntimeGCPauses     = RuntimePrefix + "gc.pause"
+	RuntimeGCCount      = RuntimePrefix + "gc.count"
+	RuntimeGoroutines   = RuntimePrefix + "goroutines"
+	RuntimeHeapAlloc    = RuntimePrefix + "heap.alloc"
+	RuntimeHeapSys      = RuntimePrefix + "heap.sys"
+	RuntimeHeapIdle     = RuntimePrefix + "heap.idle"
+	RuntimeHeapObjects  = RuntimePrefix + "heap.objects"
+	RuntimeSchedLatency = RuntimePrefix + "sched.latency"
+)
+
+// RuntimeCollector records Go runtime metrics into a registry so that they show
+// up next to the rest of the states. When built with Go 1.17 or later, the
+// metrics are read with runtime/metrics, which does not stop the world, and
+// include scheduler latencies. Before that, the heap is read with
+// runtime.ReadMemStats, which stops the world. The zero value is ready to use.
+type RuntimeCollector struct {
+	// Registry is the registry to record into. If nil, Default is used.
+	Registry *Registry
+
+	mu    sync.Mutex
+	numGC int64
+	sched []uint64 // cumulative scheduler latency bucket counts
+}
+
+// registry returns the registry the RuntimeCollector records into.
+func (c *RuntimeCollector) registry() *Registry {
+	if c.Registry == nil {
+		return Default
+	}
+	return c.Registry
+}
+
+// Run calls Read every interval until the context is canceled.
+func (c *RuntimeCollector) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		c.Read()
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Read records the runtime metrics. Pauses and scheduler latencies since the
+// previous call are added to their timers.
+func (c *RuntimeCollector) Read() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	r := c.registry()
+
+	var stats debug.GCStats
+	debug.ReadGCStats(&stats)
+	if n := stats.NumGC - c.numGC; n > 0 {
+		if n > int64(len(stats.Pause)) {
+			n = int64(len(stats.Pause))
+		}
+		state := r.GetState(RuntimeGCPauses)
+		for _, pause := range stats.Pause[:n] {
+			state.observe(int64(pause), 1, "", "")
+		}
+		r.GetCounter(RuntimeGCCount).Add(stats.NumGC - c.numGC)
+		c.numGC = stats.NumGC
+	}
+
+	r.GetGauge(RuntimeGoroutines).Set(float64(runtime.NumGoroutine()))
+	readHeap(r)
+	c.sched = readSchedLatency(r, c.sched)
+}
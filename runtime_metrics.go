@@ -0,0 +1,84 @@
+// +build !nomon,go1.17
+
+package mon
+
+import (
+	"math"
+	"runtime/metrics"
+)
+
+// schedLatencyMetric is the runtime metric for scheduler latencies.
+const schedLatencyMetric = "/sched/latencies:seconds"
+
+// heapMetrics are the runtime metrics the heap gauges are computed from.
+var heapMetrics = [...]string{
+	"/memory/classes/heap/objects:bytes",
+	"/memory/classes/heap/unused:bytes",
+	"/memory/classes/heap/free:bytes",
+	"/memory/classes/heap/released:bytes",
+	"/gc/heap/objects:objects",
+}
+
+// readHeap records the heap gauges. They are computed from runtime/metrics to
+// match the fields of runtime.MemStats without stopping the world.
+func readHeap(r *Registry) {
+	var samples [len(heapMetrics)]metrics.Sample
+	for i, name := range heapMetrics {
+		samples[i].Name = name
+	}
+	metrics.Read(samples[:])
+
+	var values [len(heapMetrics)]float64
+	for i, sample := range samples {
+		if sample.Value.Kind() == metrics.KindUint64 {
+			values[i] = float64(sample.Value.Uint64())
+		}
+	}
+	objects, unused, free, released, count := values[0], values[1], values[2], values[3], values[4]
+
+	r.GetGauge(RuntimeHeapAlloc).Set(objects)
+	r.GetGauge(RuntimeHeapSys).Set(objects + unused + free + released)
+	r.GetGauge(RuntimeHeapIdle).Set(free + released)
+	r.GetGauge(RuntimeHeapObjects).Set(count)
+}
+
+// readSchedLatency records the scheduler latencies counted since the previous
+// bucket counts and returns the current ones.
+func readSchedLatency(r *Registry, prev []uint64) []uint64 {
+	sample := []metrics.Sample{{Name: schedLatencyMetric}}
+	metrics.Read(sample)
+	if sample[0].Value.Kind() != metrics.KindFloat64Histogram {
+		return prev
+	}
+
+	his := sample[0].Value.Float64Histogram()
+	if len(prev) != len(his.Counts) {
+		prev = make([]uint64, len(his.Counts))
+	}
+
+	var state *State
+	for i, count := range his.Counts {
+		if count <= prev[i] {
+			continue
+		}
+		if state == nil {
+			state = r.GetState(RuntimeSchedLatency)
+		}
+		state.observe(bucketNanos(his.Buckets[i], his.Buckets[i+1]), int64(count-prev[i]), "", "")
+		prev[i] = count
+	}
+	return prev
+}
+
+// bucketNanos returns the duration in nanoseconds that represents a bucket with
+// the bounds in seconds, either of which may be infinite.
+func bucketNanos(lo, hi float64) int64 {
+	switch {
+	case math.IsInf(hi, 1):
+		return int64(lo * 1e9)
+	case math.IsInf(lo, -1):
+		return int64(hi * 1e9)
+	default:
+		return int64((lo + hi) / 2 * 1e9)
+	}
+}
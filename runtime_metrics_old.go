@@ -0,0 +1,20 @@
+// +build !nomon,!go1.17
+
+package mon
+
+import "runtime"
+
+// readHeap records the heap gauges. Before Go 1.17, they are read with
+// runtime.ReadMemStats, which stops the world.
+func readHeap(r *Registry) {
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+	r.GetGauge(RuntimeHeapAlloc).Set(float64(mem.HeapAlloc))
+	r.GetGauge(RuntimeHeapSys).Set(float64(mem.HeapSys))
+	r.GetGauge(RuntimeHeapIdle).Set(float64(mem.HeapIdle))
+	r.GetGauge(RuntimeHeapObjects).Set(float64(mem.HeapObjects))
+}
+
+// readSchedLatency does nothing because scheduler latencies are not available
+// before Go 1.17.
+func readSchedLatency(r *Registry, prev []uint64) []uint64 { return prev }
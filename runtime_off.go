@@ -0,0 +1,35 @@
+// +build nomon
+
+package mon
+
+import (
+	"context"
+	"time"
+)
+
+// RuntimePrefix is the prefix of the names a RuntimeCollector records under.
+const RuntimePrefix = "go."
+
+// The names a RuntimeCollector records under.
+const (
+	RuntimeGCPauses     = RuntimePrefix + "gc.pause"
+	RuntimeGCCount      = RuntimePrefix + "gc.count"
+	RuntimeGoroutines   = RuntimePrefix + "goroutines"
+	RuntimeHeapAlloc    = RuntimePrefix + "heap.alloc"
+	RuntimeHeapSys      = RuntimePrefix + "heap.sys"
+	RuntimeHeapIdle     = RuntimePrefix + "heap.idle"
+	RuntimeHeapObjects  = RuntimePrefix + "heap.objects"
+	RuntimeSchedLatency = RuntimePrefix + "sched.latency"
+)
+
+// RuntimeCollector records Go runtime metrics into a registry. With the nomon
+// tag, nothing is recorded.
+type RuntimeCollector struct {
+	Registry *Registry
+}
+
+// Run calls Read every interval until the context is canceled.
+func (c *RuntimeCollector) Run(ctx context.Context, interval time.Duration) { <-ctx.Done() }
+
+// Read records the runtime metrics.
+func (c *RuntimeCollector) Read() {}
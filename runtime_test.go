@@ -0,0 +1,36 @@
+// +build !nomon
+
+package mon
+
+import (
+	"context"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/zeebo/assert"
+)
+
+func TestRuntimeCollector(t *testing.T) {
+	var reg Registry
+	c := RuntimeCollector{Registry: &reg}
+
+	runtime.GC()
+	c.Read()
+	pauses := reg.LookupState(RuntimeGCPauses).Total()
+	assert.That(t, pauses > 0)
+	assert.Equal(t, reg.GetCounter(RuntimeGCCount).Value(), pauses)
+	assert.That(t, reg.GetGauge(RuntimeGoroutines).Value() > 0)
+	assert.That(t, reg.GetGauge(RuntimeHeapAlloc).Value() > 0)
+	assert.That(t, reg.GetGauge(RuntimeHeapObjects).Value() > 0)
+	assert.That(t, reg.GetGauge(RuntimeHeapSys).Value() >= reg.GetGauge(RuntimeHeapAlloc).Value())
+	assert.That(t, reg.GetGauge(RuntimeHeapSys).Value() >= reg.GetGauge(RuntimeHeapIdle).Value())
+
+	runtime.GC()
+	c.Read()
+	assert.That(t, reg.LookupState(RuntimeGCPauses).Total() > pauses)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	c.Run(ctx, time.Hour)
+}
@@ -0,0 +1,107 @@
+// +build !nomon
+
+package mon
+
+import (
+	"math"
+	"sync/atomic"
+	_ "unsafe"
+)
+
+//go:linkname fastrandn runtime.fastrandn
+func fastrandn(n uint32) uint32
+
+// SetSampleRate sets the sample rate of the Default registry. See
+// Registry.SetSampleRate.
+func SetSampleRate(n int) { Default.SetSampleRate(n) }
+
+// SetNameSampleRate sets the sample rate for a name in the Default registry.
+// See Registry.SetNameSampleRate.
+func SetNameSampleRate(name string, n int) { Default.SetNameSampleRate(name, n) }
+
+// SetSampleRate sets the rate timers are sampled at, so that about one in every
+// n calls is timed and the rest get a Timer that does nothing. Each timed call
+// is recorded n times so that totals, sums and quantiles remain unbiased
+// estimates, but Current and Peak only count timed calls. A rate of 1 or less
+// times every call.
+func (r *Registry) SetSampleRate(n int) {
+	r.mu.Lock()
+	atomic.StoreUint32(&r.rate, clampRate(n))
+	atomic.AddUint32(&r.confVer, 1)
+	r.mu.Unlock()
+}
+
+// SetNameSampleRate sets the sample rate for timers with the name, regardless
+// of their labels, overriding the rate from SetSampleRate. A rate of 0 or less
+// removes the override.
+func (r *Registry) SetNameSampleRate(name string, n int) {
+	r.mu.Lock()
+	old, _ := r.rates.Load().(map[string]uint32)
+	rates := make(map[string]uint32, len(old)+1)
+	for k, v := range old {
+		rates[k] = v
+	}
+	if n > 0 {
+		rates[name] = clampRate(n)
+	} else {
+		delete(rates, name)
+	}
+	r.rates.Store(rates)
+	atomic.AddUint32(&r.confVer, 1)
+	r.mu.Unlock()
+}
+
+// clampRate returns the sample rate n as a uint32, where rates below 1 time
+// every call.
+func clampRate(n int) uint32 {
+	if n < 1 {
+		return 1
+	} else if uint64(n) > math.MaxUint32 {
+		return math.MaxUint32
+	}
+	return uint32(n)
+}
+
+// presample decides if a call should be skipped before its name is known or
+// its state is looked up, which is possible when no name has its own sample
+// rate. It returns false for decided if startState must decide instead.
+func (r *Registry) presample() (skip, decided bool) {
+	if rates, _ := r.rates.Load().(map[string]uint32); len(rates) > 0 {
+		return false, false
+	}
+	n := atomic.LoadUint32(&r.rate)
+	return n > 1 && fastrandn(n) != 0, true
+}
+
+// sampleRate returns the sample rate for the state, resolving it and the slow
+// threshold again if either has changed in the registry since they were last
+// resolved.
+func (s *State) sampleRate(r *Registry) int64 {
+	ver := uint64(atomic.LoadUint32(&r.confVer)) + 1
+	if v := atomic.LoadUint64(&s.rate); v>>32 == ver {
+		return int64(uint32(v))
+	}
+
+	rate := atomic.LoadUint32(&r.rate)
+	if rates, _ := r.rates.Load().(map[string]uint32); rates != nil {
+		if n, ok := rates[s.name]; ok {
+			rate = n
+		}
+	}
+	if rate == 0 {
+		rate = 1
+	}
+
+	s.resolveSlow(r)
+	atomic.StoreUint64(&s.rate, ver<<32|uint64(rate))
+	return int64(rate)
+}
+
+// SampleRate returns the sample rate the state was last timed with. A rate of
+// n means about one in every n calls was timed.
+func (s *State) SampleRate() int64 {
+	if rate := int64(uint32(atomic.LoadUint64(&s.rate))); rate > 1 {
+		return rate
+	}
+	return 1
+}
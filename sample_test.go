@@ -0,0 +1,51 @@
+// +build !nomon
+
+package mon
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+
+	"github.com/zeebo/assert"
+)
+
+func TestSample(t *testing.T) {
+	var reg Registry
+	reg.SetSampleRate(10)
+	reg.SetNameSampleRate("exact", 1)
+
+	err := errors.New("oops")
+	for i := 0; i < 100000; i++ {
+		reg.StartNamed("sampled").Stop(&err)
+		reg.StartNamed("exact").Stop(nil)
+	}
+
+	sampled := reg.LookupState("sampled")
+	assert.Equal(t, sampled.SampleRate(), 10)
+	assert.That(t, sampled.Total() > 80000 && sampled.Total() < 120000)
+	assert.Equal(t, sampled.Total()%10, 0)
+	assert.Equal(t, atomic.LoadInt64((*int64)(sampled.Errors().Lookup("oops"))), sampled.Total())
+	assert.Equal(t, sampled.Current(), 0)
+
+	exact := reg.LookupState("exact")
+	assert.Equal(t, exact.SampleRate(), 1)
+	assert.Equal(t, exact.Total(), 100000)
+
+	reg.SetNameSampleRate("exact", 0)
+	for i := 0; i < 1000; i++ {
+		reg.StartNamed("exact").Stop(nil)
+	}
+	assert.Equal(t, exact.SampleRate(), 10)
+
+	reg.SetSampleRate(0)
+	reg.StartNamed("sampled").Stop(nil)
+	assert.Equal(t, sampled.SampleRate(), 1)
+
+	reg.SetSampleRate(-10)
+	for i := 0; i < 100; i++ {
+		reg.StartNamed("negative").Stop(nil)
+	}
+	assert.Equal(t, reg.LookupState("negative").SampleRate(), 1)
+	assert.Equal(t, reg.LookupState("negative").Total(), 100)
+}
@@ -0,0 +1,104 @@
+// +build !nomon
+
+package mon
+
+import (
+	"sync/atomic"
+	"unsafe"
+
+	"github.com/zeebo/mon/internal/lfht"
+)
+
+// shared is the part of the states for a key that is kept across Collect
+// because it describes more than one generation: the calls in flight, which may
+// have started on a state from an earlier generation, and the rolling windows.
+type shared struct {
+	current int64          // calls that have started but not completed
+	windows unsafe.Pointer // *windows when TrackWindows is enabled
+}
+
+func newShared(string) unsafe.Pointer { return unsafe.Pointer(new(shared)) }
+
+// live reports if the shared state has calls in flight or windows that have
+// not expired at the time.
+func (sh *shared) live(now int64) bool {
+	if atomic.LoadInt64(&sh.current) != 0 {
+		return true
+	}
+	w := (*windows)(atomic.LoadPointer(&sh.windows))
+	return w != nil && !w.expired(now)
+}
+
+// loadShared returns the shared state for the state, allocating one for the
+// state alone if it was not attached by a registry.
+func (s *State) loadShared() *shared {
+	sh := (*shared)(atomic.LoadPointer(&s.shared))
+	if sh == nil {
+		sh = new(shared)
+		if !atomic.CompareAndSwapPointer(&s.shared, nil, unsafe.Pointer(sh)) {
+			sh = (*shared)(atomic.LoadPointer(&s.shared))
+		}
+	}
+	return sh
+}
+
+// attachShared gives the state stored under the key the shared state for the
+// key in the table, creating it if necessary. Calls still in flight from
+// earlier generations count towards the peak of the state. It must be called
+// while holding a tracker token so that Collect can wait for it to finish.
+func (s *State) attachShared(table *unsafe.Pointer, key string) {
+	if atomic.LoadPointer(&s.shared) != nil {
+		return
+	}
+	sh := sharedTable(table).UpsertKey(key, newShared)
+	if atomic.CompareAndSwapPointer(&s.shared, nil, sh) {
+		s.setPeak(atomic.LoadInt64(&(*shared)(sh).current))
+	}
+}
+
+// sharedTable returns the table of shared states, allocating it if necessary.
+func sharedTable(table *unsafe.Pointer) *lfht.Table {
+	t := (*lfht.Table)(atomic.LoadPointer(table))
+	if t == nil {
+		t = new(lfht.Table)
+		if !atomic.CompareAndSwapPointer(table, nil, unsafe.Pointer(t)) {
+			t = (*lfht.Table)(atomic.LoadPointer(table))
+		}
+	}
+	return t
+}
+
+// pruneShared replaces the table of shared states with one holding only the
+// ones used by a state in the current generation or that are live, which keeps
+// removed names from accumulating, and returns the previous table. States may
+// attach to the previous table until the generation changes, so keepShared
+// must be called after that. It must be called with the registry mutex held.
+func pruneShared(table *unsafe.Pointer, states *lfht.Table, now int64) *lfht.Table {
+	prev := (*lfht.Table)(atomic.LoadPointer(table))
+	if prev == nil {
+		return nil
+	}
+	next := new(lfht.Table)
+	for iter := prev.Iterator(); iter.Next(); {
+		if sh := iter.Value(); states.Lookup(iter.Key()) != nil || (*shared)(sh).live(now) {
+			next.Upsert(iter.Key(), func() unsafe.Pointer { return sh })
+		}
+	}
+	atomic.StorePointer(table, unsafe.Pointer(next))
+	return prev
+}
+
+// keepShared adds the live shared states in the previous table to the table,
+// unless it already has one for the key, so that states attached to the
+// previous table while pruneShared ran are kept. It must be called with the
+// registry mutex held, after the generation has changed.
+func keepShared(table *unsafe.Pointer, prev *lfht.Table, now int64) {
+	if prev == nil {
+		return
+	}
+	for iter := prev.Iterator(); iter.Next(); {
+		if sh := iter.Value(); (*shared)(sh).live(now) {
+			sharedTable(table).Upsert(iter.Key(), func() unsafe.Pointer { return sh })
+		}
+	}
+}
@@ -0,0 +1,239 @@
+// +build !nomon
+
+package mon
+
+import (
+	"math"
+	"sort"
+	"sync/atomic"
+	"time"
+
+	"github.com/zeebo/mon/internal/lfht"
+	"github.com/zeebo/mon/inthist"
+)
+
+// Objective is a service level objective for the timers with some name, like
+// 99% of calls taking at most 200ms and at most 0.1% of calls failing.
+type Objective struct {
+	// Name is the name of the timers. Timers with any labels are combined.
+	Name string
+
+	// Latency and LatencyTarget require at least LatencyTarget of the calls,
+	// like 0.99, to take at most Latency. A zero LatencyTarget has no latency
+	// objective.
+	Latency       time.Duration
+	LatencyTarget float64
+
+	// MaxErrorRatio is the largest ratio of calls that may fail, like 0.001.
+	// A zero MaxErrorRatio has no error objective.
+	MaxErrorRatio float64
+
+	// Good returns true if calls with the error kind still succeeded, like
+	// the "2xx" status class recorded by monhttp. If nil, calls with any
+	// error kind failed.
+	Good func(kind string) bool
+}
+
+// ObjectiveStatus is how an Objective is doing over some window.
+type ObjectiveStatus struct {
+	Objective
+
+	// Window is how far back the status looks, or zero for everything since
+	// the timers were created. Like State.Window, the status covers the whole
+	// minutes before the current one.
+	Window time.Duration
+
+	// Total is the number of calls in the window.
+	Total int64
+
+	// LatencyCompliance is the ratio of the calls that took at most Latency,
+	// or 1 without a latency objective, and ErrorRatio is the ratio of the
+	// calls that failed.
+	LatencyCompliance float64
+	ErrorRatio        float64
+
+	// LatencyBurn and ErrorBurn are how many times faster than allowed the
+	// error budgets of the objective are being spent. A burn rate of 1 spends
+	// exactly the budget, and a burn rate above 1 violates the objective.
+	LatencyBurn float64
+	ErrorBurn   float64
+}
+
+// Met returns true if neither error budget is burning faster than allowed.
+func (s ObjectiveStatus) Met() bool { return s.LatencyBurn <= 1 && s.ErrorBurn <= 1 }
+
+// objectiveWindows are the rolling windows objectives are evaluated over in
+// addition to the cumulative one.
+var objectiveWindows = [...]time.Duration{time.Minute, 5 * time.Minute, 15 * time.Minute}
+
+// SetObjective sets an objective in the Default registry. See
+// Registry.SetObjective.
+func SetObjective(o Objective) { Default.SetObjective(o) }
+
+// RemoveObjective removes an objective from the Default registry.
+func RemoveObjective(name string) { Default.RemoveObjective(name) }
+
+// Objectives evaluates the objectives of the Default registry. See
+// Registry.Objectives.
+func Objectives() []ObjectiveStatus { return Default.Objectives() }
+
+// SetObjective sets the objective for the timers with its name, replacing any
+// previous objective for them.
+func (r *Registry) SetObjective(o Objective) {
+	r.updateObjectives(func(objectives map[string]Objective) { objectives[o.Name] = o })
+}
+
+// RemoveObjective removes the objective for the timers with the name.
+func (r *Registry) RemoveObjective(name string) {
+	r.updateObjectives(func(objectives map[string]Objective) { delete(objectives, name) })
+}
+
+// updateObjectives replaces the objectives with a modified copy.
+func (r *Registry) updateObjectives(fn func(map[string]Objective)) {
+	r.mu.Lock()
+	old, _ := r.objectives.Load().(map[string]Objective)
+	objectives := make(map[string]Objective, len(old)+1)
+	for k, v := range old {
+		objectives[k] = v
+	}
+	fn(objectives)
+	r.objectives.Store(objectives)
+	r.mu.Unlock()
+}
+
+// objectiveCalls accumulates the calls of the timers for an objective during
+// some window.
+type objectiveCalls struct {
+	his    inthist.Histogram
+	failed int64
+}
+
+// fail counts calls with the error kind as failed unless the objective says
+// they are good.
+func (c *objectiveCalls) fail(o *Objective, kind string, count int64) {
+	if o.Good == nil || !o.Good(kind) {
+		c.failed += count
+	}
+}
+
+// Objectives evaluates every objective against the timers with its name,
+// returning a status for everything since the timers were created followed by
+// ones for the last 1, 5 and 15 whole minutes if TrackWindows is enabled. Statuses
+// are sorted by name. Comparing the burn rates of a short and a long window
+// tells if a problem is both severe and ongoing.
+func (r *Registry) Objectives() (statuses []ObjectiveStatus) {
+	objectives, _ := r.objectives.Load().(map[string]Objective)
+	if len(objectives) == 0 {
+		return nil
+	}
+
+	now := windowNow()
+	calls := make(map[string][]*objectiveCalls, len(objectives))
+	seen := make(map[*windows]bool)
+
+	// addWindows adds the calls in the windows for an objective.
+	addWindows := func(w *windows) {
+		o, ok := objectives[w.name]
+		if !ok || seen[w] {
+			return
+		}
+		seen[w] = true
+		cs := calls[w.name]
+		if cs == nil {
+			cs = []*objectiveCalls{new(objectiveCalls)}
+		}
+		for len(cs) < len(objectiveWindows)+1 {
+			cs = append(cs, new(objectiveCalls))
+		}
+		for i, d := range objectiveWindows {
+			c := cs[i+1]
+			c.his.Merge(w.window(now, windowMinutes(d)))
+			w.errors(now, windowMinutes(d), func(kind string, count int64) { c.fail(&o, kind, count) })
+		}
+		calls[w.name] = cs
+	}
+
+	r.Times(func(_ string, st *State) bool {
+		o, ok := objectives[st.name]
+		if !ok || st.kind != KindTimer {
+			return true
+		}
+		cs := calls[st.name]
+		if cs == nil {
+			cs = []*objectiveCalls{new(objectiveCalls)}
+		}
+
+		cs[0].his.Merge(&st.timer.his)
+		for iter := st.timer.errors.Iterator(); iter.Next(); {
+			cs[0].fail(&o, iter.Key(), atomic.LoadInt64((*int64)(iter.Value())))
+		}
+		calls[st.name] = cs
+
+		if w := st.recordedWindows(); w != nil {
+			addWindows(w)
+		}
+		return true
+	})
+
+	// windows shared across Collect count even if their timers have not
+	// been used since.
+	if table := (*lfht.Table)(atomic.LoadPointer(&r.shared[0])); table != nil {
+		for iter := table.Iterator(); iter.Next(); {
+			if w := (*windows)(atomic.LoadPointer(&(*shared)(iter.Value()).windows)); w != nil {
+				addWindows(w)
+			}
+		}
+	}
+
+	for name, o := range objectives {
+		cs := calls[name]
+		if cs == nil {
+			cs = []*objectiveCalls{new(objectiveCalls)}
+		}
+		for i, c := range cs {
+			status := o.evaluate(&c.his, c.failed)
+			if i > 0 {
+				status.Window = objectiveWindows[i-1]
+			}
+			statuses = append(statuses, status)
+		}
+	}
+
+	sort.SliceStable(statuses, func(i, j int) bool { return statuses[i].Name < statuses[j].Name })
+	return statuses
+}
+
+// evaluate returns the status of the objective for the calls in the histogram,
+// some of which failed.
+func (o Objective) evaluate(his *inthist.Histogram, failed int64) ObjectiveStatus {
+	status := ObjectiveStatus{
+		Objective:         o,
+		Total:             his.Total(),
+		LatencyCompliance: 1,
+	}
+	if status.Total == 0 {
+		return status
+	}
+
+	status.ErrorRatio = float64(failed) / float64(status.Total)
+	if o.LatencyTarget > 0 {
+		status.LatencyCompliance = his.CDF(int64(o.Latency))
+		status.LatencyBurn = burnRate(1-status.LatencyCompliance, 1-o.LatencyTarget)
+	}
+	if o.MaxErrorRatio > 0 {
+		status.ErrorBurn = burnRate(status.ErrorRatio, o.MaxErrorRatio)
+	}
+	return status
+}
+
+// burnRate returns how many times the budget the bad ratio is.
+func burnRate(bad, budget float64) float64 {
+	switch {
+	case bad <= 0:
+		return 0
+	case budget <= 0:
+		return math.Inf(1)
+	default:
+		return bad / budget
+	}
+}
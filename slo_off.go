@@ -0,0 +1,47 @@
+// +build nomon
+
+package mon
+
+import "time"
+
+// Objective is a service level objective for the timers with some name.
+type Objective struct {
+	Name          string
+	Latency       time.Duration
+	LatencyTarget float64
+	MaxErrorRatio float64
+	Good          func(kind string) bool
+}
+
+// ObjectiveStatus is how an Objective is doing over some window.
+type ObjectiveStatus struct {
+	Objective
+
+	Window            time.Duration
+	Total             int64
+	LatencyCompliance float64
+	ErrorRatio        float64
+	LatencyBurn       float64
+	ErrorBurn         float64
+}
+
+// Met returns true if neither error budget is burning faster than allowed.
+func (s ObjectiveStatus) Met() bool { return s.LatencyBurn <= 1 && s.ErrorBurn <= 1 }
+
+// SetObjective sets an objective in the Default registry.
+func SetObjective(o Objective) {}
+
+// RemoveObjective removes an objective from the Default registry.
+func RemoveObjective(name string) {}
+
+// Objectives evaluates the objectives of the Default registry.
+func Objectives() []ObjectiveStatus { return nil }
+
+// SetObjective sets the objective for the timers with its name.
+func (r *Registry) SetObjective(o Objective) {}
+
+// RemoveObjective removes the objective for the timers with the name.
+func (r *Registry) RemoveObjective(name string) {}
+
+// Objectives evaluates every objective against the timers with its name.
+func (r *Registry) Objectives() []ObjectiveStatus { return nil }
@@ -0,0 +1,93 @@
+// +build !nomon
+
+package mon
+
+import (
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/zeebo/assert"
+	"github.com/zeebo/mon/inthist"
+)
+
+func TestObjectives(t *testing.T) {
+	t.Run("Evaluate", func(t *testing.T) {
+		o := Objective{Latency: 100, LatencyTarget: 0.9, MaxErrorRatio: 0.1}
+
+		his := new(inthist.Histogram)
+		for i := int64(1); i <= 100; i++ {
+			his.Observe(i * 10)
+		}
+		status := o.evaluate(his, 20)
+		assert.Equal(t, status.Total, 100)
+		assert.Equal(t, status.LatencyCompliance, 0.1)
+		assert.Equal(t, status.ErrorRatio, 0.2)
+		assert.That(t, math.Abs(status.LatencyBurn-9) < 1e-9)
+		assert.That(t, math.Abs(status.ErrorBurn-2) < 1e-9)
+		assert.That(t, !status.Met())
+
+		status = o.evaluate(new(inthist.Histogram), 0)
+		assert.Equal(t, status.LatencyCompliance, 1.0)
+		assert.That(t, status.Met())
+
+		status = Objective{MaxErrorRatio: 0.1}.evaluate(his, 0)
+		assert.Equal(t, status.LatencyCompliance, 1.0)
+		assert.Equal(t, status.LatencyBurn, 0.0)
+
+		assert.Equal(t, burnRate(0.1, 0), math.Inf(1))
+		assert.Equal(t, burnRate(0, 0), 0.0)
+	})
+
+	t.Run("Registry", func(t *testing.T) {
+		TrackWindows(true)
+		defer TrackWindows(false)
+		defer readNextMinute()()
+
+		var reg Registry
+		assert.Equal(t, len(reg.Objectives()), 0)
+		reg.SetObjective(Objective{
+			Name:          "api",
+			Latency:       time.Hour,
+			LatencyTarget: 0.99,
+			MaxErrorRatio: 0.3,
+			Good:          func(kind string) bool { return kind == "fine" },
+		})
+		reg.SetObjective(Objective{Name: "unused", MaxErrorRatio: 0.1})
+
+		bad, fine := errors.New("bad"), errors.New("fine")
+		reg.StartLabeled("api", Label{Key: "route", Value: "/a"}).Stop(nil)
+		reg.StartLabeled("api", Label{Key: "route", Value: "/b"}).Stop(&bad)
+		reg.StartNamed("api").Stop(&fine)
+		reg.StartNamed("api").Stop(nil)
+
+		statuses := reg.Objectives()
+		assert.Equal(t, len(statuses), 5)
+		for _, status := range statuses[:4] {
+			assert.Equal(t, status.Name, "api")
+			assert.Equal(t, status.Total, 4)
+			assert.Equal(t, status.ErrorRatio, 0.25)
+			assert.Equal(t, status.LatencyBurn, 0.0)
+			assert.That(t, status.Met())
+		}
+		assert.Equal(t, statuses[0].Window, time.Duration(0))
+		assert.Equal(t, statuses[3].Window, 15*time.Minute)
+		assert.Equal(t, statuses[4].Name, "unused")
+		assert.Equal(t, statuses[4].Total, 0)
+
+		reg.RemoveObjective("unused")
+		assert.Equal(t, len(reg.Objectives()), 4)
+
+		// the windows are kept across Collect even before the timers are
+		// used again.
+		reg.Collect(func(string, *State) bool { return true })
+		statuses = reg.Objectives()
+		assert.Equal(t, len(statuses), 4)
+		assert.Equal(t, statuses[0].Total, 0)
+		for _, status := range statuses[1:] {
+			assert.Equal(t, status.Total, 4)
+			assert.Equal(t, status.ErrorRatio, 0.25)
+		}
+	})
+}
@@ -0,0 +1,270 @@
+// +build !nomon
+
+package mon
+
+import (
+	"math"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unsafe"
+)
+
+// Threshold decides which calls are slow. The zero value makes no calls slow.
+type Threshold struct {
+	// Duration is how long a call must take to be slow.
+	Duration time.Duration
+
+	// Quantile, if non-zero, also requires a slow call to take longer than
+	// that quantile of the durations recorded by its State, like 0.999. No
+	// calls are slow until the State has recorded enough of them for the
+	// quantile to be meaningful. To keep Stop cheap, the quantile is only
+	// computed again once every 64 calls that reach Duration.
+	Quantile float64
+
+	// Stack causes the stack of every call to be captured when it starts, so
+	// that slow calls include where they came from. It is expensive.
+	Stack bool
+}
+
+// SlowCall describes a call that took longer than its Threshold.
+type SlowCall struct {
+	Name     string
+	Labels   []Label
+	Start    time.Time
+	Duration time.Duration
+	Kind     string // error kind, if any
+	Err      error  // error passed to Stop, if any
+	Stack    string // stack from when the call started, if captured
+}
+
+// slowConfig is the thresholds and hooks of a registry.
+type slowConfig struct {
+	def   Threshold
+	names map[string]Threshold
+	hooks []func(SlowCall)
+}
+
+// slowThreshold is the Threshold resolved for a State.
+type slowThreshold struct {
+	Threshold
+	reg      *Registry
+	calls    int64 // calls checked against the quantile
+	quantile int64 // cached quantile, or math.MaxInt64 if too few calls
+}
+
+const (
+	// slowCalls is how many of the most recent slow calls a registry keeps.
+	slowCalls = 64
+
+	// slowQuantileCalls is how many calls a cached quantile is used for.
+	slowQuantileCalls = 64
+)
+
+// slowRing keeps the most recent slow calls.
+type slowRing struct {
+	mu    sync.Mutex
+	calls [slowCalls]SlowCall
+	next  uint64
+}
+
+// SetSlowThreshold sets the slow threshold of the Default registry. See
+// Registry.SetSlowThreshold.
+func SetSlowThreshold(t Threshold) { Default.SetSlowThreshold(t) }
+
+// SetNameSlowThreshold sets the slow threshold for a name in the Default
+// registry. See Registry.SetNameSlowThreshold.
+func SetNameSlowThreshold(name string, t Threshold) { Default.SetNameSlowThreshold(name, t) }
+
+// RegisterSlowHook adds a hook for slow calls in the Default registry. See
+// Registry.RegisterSlowHook.
+func RegisterSlowHook(hook func(SlowCall)) { Default.RegisterSlowHook(hook) }
+
+// SlowCalls returns the most recent slow calls in the Default registry.
+func SlowCalls() []SlowCall { return Default.SlowCalls() }
+
+// SetSlowThreshold sets the threshold that timers must cross for their calls to
+// be slow. Slow calls are passed to the hooks and kept for SlowCalls.
+func (r *Registry) SetSlowThreshold(t Threshold) {
+	r.updateSlow(func(c *slowConfig) { c.def = t })
+}
+
+// SetNameSlowThreshold sets the slow threshold for timers with the name,
+// regardless of their labels, overriding the threshold from SetSlowThreshold.
+// A zero Threshold removes the override.
+func (r *Registry) SetNameSlowThreshold(name string, t Threshold) {
+	r.updateSlow(func(c *slowConfig) {
+		names := make(map[string]Threshold, len(c.names)+1)
+		for k, v := range c.names {
+			names[k] = v
+		}
+		if t != (Threshold{}) {
+			names[name] = t
+		} else {
+			delete(names, name)
+		}
+		c.names = names
+	})
+}
+
+// RegisterSlowHook adds a hook that is called with every slow call. Hooks are
+// called by Timer.Stop, so they should be quick.
+func (r *Registry) RegisterSlowHook(hook func(SlowCall)) {
+	r.updateSlow(func(c *slowConfig) {
+		c.hooks = append(c.hooks[:len(c.hooks):len(c.hooks)], hook)
+	})
+}
+
+// updateSlow replaces the slow config with a modified copy.
+func (r *Registry) updateSlow(fn func(*slowConfig)) {
+	r.mu.Lock()
+	var c slowConfig
+	if old, _ := r.slow.Load().(*slowConfig); old != nil {
+		c = *old
+	}
+	fn(&c)
+	r.slow.Store(&c)
+	atomic.AddUint32(&r.confVer, 1)
+	r.mu.Unlock()
+}
+
+// SlowCalls returns the most recent slow calls, newest first.
+func (r *Registry) SlowCalls() (calls []SlowCall) {
+	ring := &r.slowLog
+	ring.mu.Lock()
+	for i := ring.next; i > 0 && ring.next-i < slowCalls; i-- {
+		calls = append(calls, ring.calls[(i-1)%slowCalls])
+	}
+	ring.mu.Unlock()
+	return calls
+}
+
+// resolveSlow stores the slow threshold for the state, or nil if it has none.
+func (s *State) resolveSlow(r *Registry) {
+	var t *slowThreshold
+	if c, _ := r.slow.Load().(*slowConfig); c != nil {
+		th, ok := c.names[s.name]
+		if !ok {
+			th = c.def
+		}
+		if th != (Threshold{}) {
+			t = &slowThreshold{Threshold: th, reg: r, quantile: math.MaxInt64}
+		}
+	}
+	atomic.StorePointer(&s.slow, unsafe.Pointer(t))
+}
+
+// slowThreshold returns the slow threshold for the state, or nil if it has none.
+func (s *State) slowThreshold() *slowThreshold {
+	return (*slowThreshold)(atomic.LoadPointer(&s.slow))
+}
+
+// isSlow returns true if a call to the state that took v nanoseconds is slow.
+func (t *slowThreshold) isSlow(s *State, v int64) bool {
+	if v < int64(t.Duration) {
+		return false
+	}
+	if t.Quantile > 0 {
+		return v > t.stateQuantile(s)
+	}
+	return true
+}
+
+// stateQuantile returns the Quantile of the durations recorded by the state,
+// or math.MaxInt64 if too few have been recorded, only computing it again
+// once every slowQuantileCalls calls.
+func (t *slowThreshold) stateQuantile(s *State) int64 {
+	if (atomic.AddInt64(&t.calls, 1)-1)%slowQuantileCalls != 0 {
+		return atomic.LoadInt64(&t.quantile)
+	}
+	q := int64(math.MaxInt64)
+	if float64(s.Total())*(1-t.Quantile) >= 1 {
+		q = s.Quantile(t.Quantile)
+	}
+	atomic.StoreInt64(&t.quantile, q)
+	return q
+}
+
+// reportSlow reports a call to the state that just ended after v nanoseconds
+// if it was slow, with the stack from the ext if it has one.
+func (s *State) reportSlow(v int64, kind string, err error, ext *timerExt) {
+	if t := s.slowThreshold(); t != nil && t.isSlow(s, v) {
+		t.report(SlowCall{
+			Name:     s.name,
+			Labels:   s.labels,
+			Start:    time.Now().Add(-time.Duration(v)),
+			Duration: time.Duration(v),
+			Kind:     kind,
+			Err:      err,
+			Stack:    ext.formatStack(),
+		})
+	}
+}
+
+// report passes the slow call to the hooks and keeps it.
+func (t *slowThreshold) report(call SlowCall) {
+	ring := &t.reg.slowLog
+	ring.mu.Lock()
+	ring.calls[ring.next%slowCalls] = call
+	ring.next++
+	ring.mu.Unlock()
+
+	if c, _ := t.reg.slow.Load().(*slowConfig); c != nil {
+		for _, hook := range c.hooks {
+			hook(call)
+		}
+	}
+}
+
+// captureStack returns the program counters of the stack starting at the
+// function that called it. The frames that started the Timer are left in, and
+// removed by formatStack.
+func captureStack() []uintptr {
+	pcs := make([]uintptr, 32)
+	return pcs[:runtime.Callers(2, pcs)]
+}
+
+// formatStack formats the captured stack like a panic would, starting at the
+// caller that started the Timer.
+func (e *timerExt) formatStack() string {
+	if e == nil || len(e.stack) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	frames := runtime.CallersFrames(e.stack)
+	frame, more := frames.Next()
+	for more && startFrame(frame.Function) {
+		frame, more = frames.Next()
+	}
+	for {
+		b.WriteString(frame.Function)
+		b.WriteString("\n\t")
+		b.WriteString(frame.File)
+		b.WriteByte(':')
+		b.WriteString(strconv.Itoa(frame.Line))
+		b.WriteByte('\n')
+		if !more {
+			return b.String()
+		}
+		frame, more = frames.Next()
+	}
+}
+
+// monPackage is the prefix of the names of the functions in this package.
+const monPackage = "github.com/zeebo/mon."
+
+// startFrame returns true if the function is one in this package that starts
+// a Timer, like Registry.StartNamed or startState.
+func startFrame(fn string) bool {
+	if !strings.HasPrefix(fn, monPackage) {
+		return false
+	}
+	fn = fn[len(monPackage):]
+	if i := strings.LastIndexByte(fn, '.'); i >= 0 {
+		fn = fn[i+1:]
+	}
+	return strings.HasPrefix(fn, "Start") || strings.HasPrefix(fn, "start")
+}
@@ -0,0 +1,48 @@
+// +build nomon
+
+package mon
+
+import "time"
+
+// Threshold decides which calls are slow.
+type Threshold struct {
+	Duration time.Duration
+	Quantile float64
+	Stack    bool
+}
+
+// SlowCall describes a call that took longer than its Threshold.
+type SlowCall struct {
+	Name     string
+	Labels   []Label
+	Start    time.Time
+	Duration time.Duration
+	Kind     string
+	Err      error
+	Stack    string
+}
+
+// SetSlowThreshold sets the slow threshold of the Default registry.
+func SetSlowThreshold(t Threshold) {}
+
+// SetNameSlowThreshold sets the slow threshold for a name in the Default registry.
+func SetNameSlowThreshold(name string, t Threshold) {}
+
+// RegisterSlowHook adds a hook for slow calls in the Default registry.
+func RegisterSlowHook(hook func(SlowCall)) {}
+
+// SlowCalls returns the most recent slow calls in the Default registry.
+func SlowCalls() []SlowCall { return nil }
+
+// SetSlowThreshold sets the threshold that timers must cross for their calls to
+// be slow.
+func (r *Registry) SetSlowThreshold(t Threshold) {}
+
+// SetNameSlowThreshold sets the slow threshold for timers with the name.
+func (r *Registry) SetNameSlowThreshold(name string, t Threshold) {}
+
+// RegisterSlowHook adds a hook that is called with every slow call.
+func (r *Registry) RegisterSlowHook(hook func(SlowCall)) {}
+
+// SlowCalls returns the most recent slow calls.
+func (r *Registry) SlowCalls() []SlowCall { return nil }
@@ -0,0 +1,93 @@
+// +build !nomon
+
+package mon
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zeebo/assert"
+)
+
+func TestSlow(t *testing.T) {
+	t.Run("Threshold", func(t *testing.T) {
+		var reg Registry
+		var hooked []SlowCall
+		reg.RegisterSlowHook(func(call SlowCall) { hooked = append(hooked, call) })
+
+		reg.StartNamed("fast").Stop(nil)
+		assert.Equal(t, len(reg.SlowCalls()), 0)
+
+		reg.SetSlowThreshold(Threshold{Duration: time.Millisecond})
+		reg.SetNameSlowThreshold("always", Threshold{Duration: 1, Stack: true})
+
+		reg.StartNamed("fast").Stop(nil)
+		err := errors.New("problem")
+		reg.StartNamed("always").Stop(&err)
+		timer := reg.StartNamed("sleepy")
+		time.Sleep(2 * time.Millisecond)
+		timer.Stop(nil)
+
+		calls := reg.SlowCalls()
+		assert.Equal(t, len(calls), 2)
+		assert.DeepEqual(t, calls, []SlowCall{hooked[1], hooked[0]})
+		assert.Equal(t, calls[0].Name, "sleepy")
+		assert.That(t, calls[0].Duration >= 2*time.Millisecond)
+		assert.Equal(t, calls[0].Stack, "")
+		assert.Equal(t, calls[1].Name, "always")
+		assert.Equal(t, calls[1].Kind, "problem")
+		assert.Equal(t, calls[1].Err, err)
+		assert.That(t, strings.HasPrefix(calls[1].Stack, "github.com/zeebo/mon.TestSlow"))
+
+		// removing the override falls back to the default threshold
+		reg.SetNameSlowThreshold("always", Threshold{})
+		reg.StartNamed("always").Stop(nil)
+		assert.Equal(t, len(reg.SlowCalls()), 2)
+	})
+
+	t.Run("Quantile", func(t *testing.T) {
+		var reg Registry
+		reg.SetSlowThreshold(Threshold{Quantile: 0.9})
+
+		for i := 0; i < 9; i++ {
+			reg.StartNamed("name").Stop(nil)
+		}
+		assert.Equal(t, len(reg.SlowCalls()), 0)
+
+		for i := 0; i < 100; i++ {
+			reg.StartNamed("name").Stop(nil)
+		}
+		timer := reg.StartNamed("name")
+		time.Sleep(time.Millisecond)
+		timer.Stop(nil)
+
+		calls := reg.SlowCalls()
+		assert.That(t, len(calls) > 0)
+		assert.Equal(t, calls[0].Name, "name")
+		assert.That(t, calls[0].Duration >= time.Millisecond)
+	})
+
+	t.Run("Stack", func(t *testing.T) {
+		var reg Registry
+		reg.SetSlowThreshold(Threshold{Duration: 1, Stack: true})
+
+		_, timer := reg.StartNamedContext(context.Background(), "name")
+		timer.Stop(nil)
+
+		calls := reg.SlowCalls()
+		assert.Equal(t, len(calls), 1)
+		assert.That(t, strings.HasPrefix(calls[0].Stack, "github.com/zeebo/mon.TestSlow.func"))
+	})
+
+	t.Run("Ring", func(t *testing.T) {
+		var reg Registry
+		reg.SetSlowThreshold(Threshold{Duration: 1})
+		for i := 0; i < 2*slowCalls; i++ {
+			reg.StartNamed("name").Stop(nil)
+		}
+		assert.Equal(t, len(reg.SlowCalls()), slowCalls)
+	})
+}
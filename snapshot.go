@@ -0,0 +1,180 @@
+// +build !nomon
+
+package mon
+
+import (
+	"sort"
+	"sync/atomic"
+	"time"
+
+	"github.com/zeebo/mon/floathist"
+	"github.com/zeebo/mon/inthist"
+)
+
+// Snapshot is an immutable copy of the states in a registry. Unlike the states
+// passed to Times, it does not change while it is being read, and unlike
+// Collect, taking one does not reset anything.
+type Snapshot struct {
+	// Time is when the snapshot was taken.
+	Time time.Time
+
+	// Elapsed is the time covered by a snapshot returned by Sub. It is zero for
+	// snapshots taken from a registry.
+	Elapsed time.Duration
+
+	// States are the copies of the states sorted by key.
+	States []StateSnapshot
+}
+
+// StateSnapshot is an immutable copy of a State. The histograms must not be
+// modified.
+type StateSnapshot struct {
+	Key    string
+	Name   string
+	Labels []Label
+	Kind   Kind
+	Unit   Unit
+
+	// Count is the number of completed calls for timers, the value of counters,
+	// the number of marked events for meters and the number of observed values
+	// for distributions.
+	Count int64
+
+	// Value is the value of gauges.
+	Value float64
+
+	// Rate is the Count per second over the Elapsed time of a snapshot returned
+	// by Sub. It is zero otherwise.
+	Rate float64
+
+	Current int64
+	Peak    int64
+	Folded  int64
+	Errors  map[string]int64
+
+	// Histogram holds the durations for timers, and Values holds the values
+	// for distributions.
+	Histogram *inthist.Histogram
+	Values    *floathist.Histogram
+
+	state *State // the state the copy was made from
+}
+
+// TakeSnapshot returns a Snapshot of the Default registry.
+func TakeSnapshot() *Snapshot { return Default.Snapshot() }
+
+// Snapshot returns a copy of all of the states in the registry.
+func (r *Registry) Snapshot() *Snapshot {
+	snap := &Snapshot{Time: time.Now()}
+	r.Times(func(key string, state *State) bool {
+		snap.States = append(snap.States, state.snapshot(key))
+		return true
+	})
+	sort.Slice(snap.States, func(i, j int) bool {
+		return snap.States[i].Key < snap.States[j].Key
+	})
+	return snap
+}
+
+// snapshot returns a copy of the state stored under the key.
+func (s *State) snapshot(key string) StateSnapshot {
+	ss := StateSnapshot{
+		Key:    key,
+		Name:   s.name,
+		Labels: s.labels,
+		Kind:   s.kind,
+		Unit:   s.Unit(),
+		Folded: s.Folded(),
+		state:  s,
+	}
+
+	switch s.kind {
+	case KindCounter, KindMeter:
+		ss.Count = atomic.LoadInt64(&s.metric.value)
+	case KindGauge:
+		ss.Value = s.Gauge().Value()
+	case KindDistribution:
+		ss.Values = new(floathist.Histogram)
+		ss.Values.Merge(s.metric.dist)
+		ss.Count = ss.Values.Total()
+	default:
+		ss.Histogram = new(inthist.Histogram)
+		ss.Histogram.Merge(&s.timer.his)
+		ss.Count = ss.Histogram.Total()
+		ss.Current = s.Current()
+		ss.Peak = s.Peak()
+		for iter := s.timer.errors.Iterator(); iter.Next(); {
+			if ss.Errors == nil {
+				ss.Errors = make(map[string]int64)
+			}
+			ss.Errors[iter.Key()] = atomic.LoadInt64((*int64)(iter.Value()))
+		}
+	}
+
+	return ss
+}
+
+// Lookup returns the copy of the state stored under the key, or nil if there
+// is none.
+func (s *Snapshot) Lookup(key string) *StateSnapshot {
+	i := sort.Search(len(s.States), func(i int) bool { return s.States[i].Key >= key })
+	if i < len(s.States) && s.States[i].Key == key {
+		return &s.States[i]
+	}
+	return nil
+}
+
+// Sub returns the changes between an earlier snapshot of the same registry and
+// this one. Counts, errors and histograms only include what was recorded in
+// between, and rates are computed over the elapsed time. Gauges, Current and
+// Peak keep their values from this snapshot. States that were replaced in
+// between, as by Collect or EvictIdle, are included in full.
+func (s *Snapshot) Sub(prev *Snapshot) *Snapshot {
+	delta := &Snapshot{
+		Time:    s.Time,
+		Elapsed: s.Time.Sub(prev.Time),
+		States:  make([]StateSnapshot, len(s.States)),
+	}
+	for i := range s.States {
+		ds := &delta.States[i]
+		*ds = s.States[i]
+		if ps := prev.Lookup(ds.Key); ps != nil && ps.state == ds.state {
+			ds.sub(ps)
+		}
+		if secs := delta.Elapsed.Seconds(); secs > 0 {
+			ds.Rate = float64(ds.Count) / secs
+		}
+	}
+	return delta
+}
+
+// sub removes everything in the earlier copy prev of the same state.
+func (ss *StateSnapshot) sub(prev *StateSnapshot) {
+	ss.Folded -= prev.Folded
+	if ss.Kind == KindGauge {
+		return
+	}
+	ss.Count -= prev.Count
+
+	if ss.Histogram != nil && prev.Histogram != nil {
+		his := new(inthist.Histogram)
+		his.Merge(ss.Histogram)
+		his.Sub(prev.Histogram)
+		ss.Histogram = his
+	}
+	if ss.Values != nil && prev.Values != nil {
+		values := new(floathist.Histogram)
+		values.Merge(ss.Values)
+		values.Sub(prev.Values)
+		ss.Values = values
+	}
+	if len(ss.Errors) > 0 {
+		errors := make(map[string]int64, len(ss.Errors))
+		for kind, count := range ss.Errors {
+			if count -= prev.Errors[kind]; count > 0 {
+				errors[kind] = count
+			}
+		}
+		ss.Errors = errors
+	}
+}
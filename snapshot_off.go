@@ -0,0 +1,51 @@
+// +build nomon
+
+package mon
+
+import (
+	"time"
+
+	"github.com/zeebo/mon/floathist"
+	"github.com/zeebo/mon/inthist"
+)
+
+// Snapshot is an immutable copy of the states in a registry. With the nomon
+// tag, it is always empty.
+type Snapshot struct {
+	Time    time.Time
+	Elapsed time.Duration
+	States  []StateSnapshot
+}
+
+// StateSnapshot is an immutable copy of a State.
+type StateSnapshot struct {
+	Key    string
+	Name   string
+	Labels []Label
+	Kind   Kind
+	Unit   Unit
+
+	Count int64
+	Value float64
+	Rate  float64
+
+	Current int64
+	Peak    int64
+	Folded  int64
+	Errors  map[string]int64
+
+	Histogram *inthist.Histogram
+	Values    *floathist.Histogram
+}
+
+// TakeSnapshot returns a Snapshot of the Default registry.
+func TakeSnapshot() *Snapshot { return new(Snapshot) }
+
+// Snapshot returns a copy of all of the states in the registry.
+func (r *Registry) Snapshot() *Snapshot { return new(Snapshot) }
+
+// Lookup returns the copy of the state stored under the key.
+func (s *Snapshot) Lookup(key string) *StateSnapshot { return nil }
+
+// Sub returns the changes between an earlier snapshot and this one.
+func (s *Snapshot) Sub(prev *Snapshot) *Snapshot { return new(Snapshot) }
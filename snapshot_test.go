@@ -0,0 +1,63 @@
+// +build !nomon
+
+package mon
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/zeebo/assert"
+)
+
+func TestSnapshot(t *testing.T) {
+	var reg Registry
+	problem := errors.New("problem")
+
+	reg.StartNamed("timer").Stop(nil)
+	reg.StartNamed("timer").Stop(&problem)
+	reg.GetCounter("counter").Add(5)
+	reg.GetGauge("gauge").Set(1.5)
+	reg.GetDistribution("dist", UnitBytes).Observe(10)
+
+	first := reg.Snapshot()
+	assert.Equal(t, len(first.States), 4)
+	assert.Nil(t, first.Lookup("missing"))
+
+	timer := first.Lookup("timer")
+	assert.Equal(t, timer.Count, 2)
+	assert.Equal(t, timer.Errors[getKind(problem)], 1)
+	assert.Equal(t, timer.Histogram.Total(), 2)
+
+	// the snapshot does not change as the registry does
+	reg.StartNamed("timer").Stop(&problem)
+	reg.GetCounter("counter").Inc()
+	reg.GetGauge("gauge").Set(3)
+	reg.GetDistribution("dist", UnitBytes).Observe(20)
+	reg.StartNamed("new").Stop(nil)
+	assert.Equal(t, timer.Histogram.Total(), 2)
+	assert.Equal(t, first.Lookup("counter").Count, 5)
+
+	second := reg.Snapshot()
+	second.Time = first.Time.Add(2 * time.Second)
+	delta := second.Sub(first)
+	assert.Equal(t, delta.Elapsed, 2*time.Second)
+
+	timer = delta.Lookup("timer")
+	assert.Equal(t, timer.Count, 1)
+	assert.Equal(t, timer.Rate, 0.5)
+	assert.Equal(t, timer.Errors[getKind(problem)], 1)
+	assert.Equal(t, timer.Histogram.Total(), 1)
+	assert.Equal(t, delta.Lookup("counter").Count, 1)
+	assert.Equal(t, delta.Lookup("gauge").Value, 3.0)
+	assert.Equal(t, delta.Lookup("dist").Count, 1)
+	assert.Equal(t, delta.Lookup("dist").Values.Quantile(1), 20.0)
+	assert.Equal(t, delta.Lookup("new").Count, 1)
+
+	// states replaced by Collect are included in full
+	reg.Collect(func(string, *State) bool { return true })
+	reg.StartNamed("timer").Stop(nil)
+	third := reg.Snapshot()
+	assert.Equal(t, third.Sub(second).Lookup("timer").Count, 1)
+	assert.Equal(t, third.Sub(second).Lookup("gauge").Value, 3.0)
+}
@@ -1,100 +1,219 @@
+// +build !nomon
+
 package mon
 
 import (
-	"sync"
 	"sync/atomic"
 	"unsafe"
 
+	"github.com/zeebo/mon/floathist"
 	"github.com/zeebo/mon/internal/lfht"
 	"github.com/zeebo/mon/inthist"
-	"github.com/zeebo/swaparoo"
-)
-
-var (
-	statesMu sync.Mutex       // protects concurrent Collect calls.
-	states   [2]lfht.Table    // states maps names to State pointers.
-	tracker  swaparoo.Tracker // keeps track of which state is valid.
 )
 
-func newState() unsafe.Pointer   { return unsafe.Pointer(new(State)) }
-func newCounter() unsafe.Pointer { return unsafe.Pointer(new(int64)) }
+func newState(name string) unsafe.Pointer { return unsafe.Pointer(newTimerState(name, nil)) }
+func newCounter() unsafe.Pointer          { return unsafe.Pointer(new(int64)) }
 
-// State keeps track of all of the timer information for some calls.
+// State keeps track of all of the information recorded for some name. Most
+// states are timers, but see Kind for the others. The storage for each kind is
+// allocated along with the State so that a State only pays for its own kind.
 type State struct {
+	name    string
+	labels  []Label
+	kind    Kind
+	peak    int64
+	shared  unsafe.Pointer // *shared with the states for the key in other generations
+	folded  int64          // lookups folded into an overflow state
+	evicted uint32         // non-zero once removed by EvictIdle
+	seen    int64          // activity as of the last EvictIdle
+	idles   int            // consecutive EvictIdle calls without activity
+	rate    uint64         // sample rate in the low bits, version+1 in the high bits
+	slow    unsafe.Pointer // *slowThreshold resolved along with the sample rate
+	reg     unsafe.Pointer // *Registry the state belongs to, once known
+	timer   *timerData     // storage for timers, or noTimer
+	metric  *metricData    // storage for the other kinds, or noMetric
+}
+
+// timerData is the storage for a timer.
+type timerData struct {
+	kinds  int64 // approximate number of error kinds
 	errors lfht.Table
 	his    inthist.Histogram
 }
 
-// Times calls the callback with all of the histograms that have been captured.
-func Times(cb func(string, *State) bool) {
-	token := tracker.Acquire()
-	for iter := states[token.Gen()%2].Iterator(); iter.Next(); {
-		if !cb(iter.Key(), (*State)(iter.Value())) {
-			goto done
-		}
+// metricData is the storage for counters, gauges, meters and distributions.
+type metricData struct {
+	value   int64                // count for counters and meters, float bits for gauges
+	created int64                // creation time for meters
+	fn      atomic.Value         // callback for gauges
+	unit    uint32               // unit for distributions
+	dist    *floathist.Histogram // values for distributions
+}
+
+// noTimer and noMetric are the storage of states of the other kinds, so that a
+// State read as the wrong kind has nothing recorded. They are never written.
+var (
+	noTimer  timerData
+	noMetric metricData
+)
+
+// timerState is a timer State allocated along with its storage.
+type timerState struct {
+	State
+	data timerData
+}
+
+// newTimerState returns a timer State for the name and labels.
+func newTimerState(name string, labels []Label) *State {
+	ts := &timerState{State: State{name: name, labels: labels, metric: &noMetric}}
+	ts.timer = &ts.data
+	return &ts.State
+}
+
+// metricState is a State of another kind allocated along with its storage.
+type metricState struct {
+	State
+	data metricData
+}
+
+// newMetricState returns a State of the kind, which must not be KindTimer, for
+// the name.
+func newMetricState(name string, kind Kind) *State {
+	ms := &metricState{State: State{name: name, kind: kind, timer: &noTimer}}
+	ms.metric = &ms.data
+	return &ms.State
+}
+
+// start informs the State that a task has begun. It returns false if the
+// State has been evicted, in which case the task must use a new State.
+func (s *State) start() bool {
+	sh := s.loadShared()
+	current := atomic.AddInt64(&sh.current, 1)
+	if atomic.LoadUint32(&s.evicted) != 0 {
+		atomic.AddInt64(&sh.current, -1)
+		return false
 	}
-done:
-	token.Release()
+	s.setPeak(current)
+	return true
 }
 
-// Collect consumes all of the histograms that have been captures and calls
-// the callback for each one.
-func Collect(cb func(string, *State) bool) {
-	statesMu.Lock()
-	gen := tracker.Increment().Wait()
-	for iter := states[gen%2].Iterator(); iter.Next(); {
-		if !cb(iter.Key(), (*State)(iter.Value())) {
-			goto done
+// setPeak raises the peak of the state to current if it is larger.
+func (s *State) setPeak(current int64) {
+	for {
+		peak := atomic.LoadInt64(&s.peak)
+		if current <= peak || atomic.CompareAndSwapInt64(&s.peak, peak, current) {
+			return
 		}
 	}
-done:
-	states[gen%2] = lfht.Table{}
-	statesMu.Unlock()
 }
 
-// GetState returns the current state for some name, allocating a new one if necessary.
-func GetState(name string) *State {
-	token := tracker.Acquire()
-	state := (*State)(states[token.Gen()%2].Upsert(name, newState))
-	token.Release()
-	return state
-}
+// finish informs the State that a task that began has ended, whether or not
+// it is recorded.
+func (s *State) finish() { atomic.AddInt64(&s.loadShared().current, -1) }
 
-// LookupState returns the current state for some name, returning nil if none exists.
-func LookupState(name string) *State {
-	token := tracker.Acquire()
-	state := (*State)(states[token.Gen()%2].Lookup(name))
-	token.Release()
-	return state
+// done informs the State that a task has completed in the given
+// amount of nanoseconds, counting it n times. If the exemplar is not
+// empty, it is kept as the exemplar for the duration.
+func (s *State) done(v, n int64, kind, exemplar string) {
+	s.finish()
+	s.observe(v, n, kind, exemplar)
 }
 
-// done informs the State that a task has completed in the given
-// amount of nanoseconds.
-func (s *State) done(v int64, kind string) {
-	s.his.Observe(v)
+// observe records a duration of v nanoseconds n times with the error kind and
+// exemplar, if any, as if that many calls completed.
+func (s *State) observe(v, n int64, kind, exemplar string) {
+	if exemplar != "" {
+		s.timer.his.ObserveExemplarN(v, n, exemplar)
+	} else if n == 1 {
+		s.timer.his.Observe(v)
+	} else {
+		s.timer.his.ObserveN(v, n)
+	}
+	if atomic.LoadUint32(&trackWindows) != 0 {
+		s.observeWindow(v, n, kind)
+	}
 	if kind != "" {
-		counter := (*int64)(s.errors.Upsert(kind, newCounter))
-		atomic.AddInt64(counter, 1)
+		s.countError(kind, n)
+	}
+}
+
+// Kind returns what the state records.
+func (s *State) Kind() Kind { return s.kind }
+
+// Counter returns the state as a Counter. It is only meaningful if the Kind is KindCounter.
+func (s *State) Counter() *Counter { return (*Counter)(s) }
+
+// Gauge returns the state as a Gauge. It is only meaningful if the Kind is KindGauge.
+func (s *State) Gauge() *Gauge { return (*Gauge)(s) }
+
+// Meter returns the state as a Meter. It is only meaningful if the Kind is KindMeter.
+func (s *State) Meter() *Meter { return (*Meter)(s) }
+
+// Distribution returns the state as a Distribution. It is only meaningful if the
+// Kind is KindDistribution.
+func (s *State) Distribution() *Distribution { return (*Distribution)(s) }
+
+// Unit returns the unit of the values recorded by the state.
+func (s *State) Unit() Unit {
+	switch s.kind {
+	case KindTimer:
+		return UnitNanoseconds
+	case KindDistribution:
+		return s.Distribution().Unit()
+	default:
+		return UnitNone
 	}
 }
 
+// Name returns the name the state was registered with, without any labels.
+func (s *State) Name() string { return s.name }
+
+// Labels returns the sorted labels the state was registered with. It must not
+// be modified.
+func (s *State) Labels() []Label { return s.labels }
+
 // Histogram returns the Histogram associated with the state.
-func (s *State) Histogram() *inthist.Histogram { return &s.his }
+func (s *State) Histogram() *inthist.Histogram { return &s.timer.his }
 
 // Errors returns a tree of error counters. Be sure to use atomic.LoadInt64 on the results.
-func (s *State) Errors() *lfht.Table { return &s.errors }
+func (s *State) Errors() *lfht.Table { return &s.timer.errors }
 
 // Total returns the number of completed calls.
-func (s *State) Total() int64 { return s.his.Total() }
+func (s *State) Total() int64 { return s.timer.his.Total() }
+
+// Current returns the number of calls that have started but not completed,
+// including calls that started before the last Collect. When calls are
+// sampled, only the timed calls are counted, so it is about one in every
+// SampleRate of the calls in flight.
+func (s *State) Current() int64 {
+	if sh := (*shared)(atomic.LoadPointer(&s.shared)); sh != nil {
+		return atomic.LoadInt64(&sh.current)
+	}
+	return 0
+}
+
+// Peak returns the largest value Current has had since the State was created.
+// Because Collect replaces every State, it is reset by Collect to the calls
+// still in flight. Like Current, it only counts timed calls.
+func (s *State) Peak() int64 { return atomic.LoadInt64(&s.peak) }
 
 // Quantile returns an estimation of the qth quantile in [0, 1].
-func (s *State) Quantile(q float64) int64 { return s.his.Quantile(q) }
+func (s *State) Quantile(q float64) int64 { return s.timer.his.Quantile(q) }
 
 // Sum returns an estimation of the sum.
-func (s *State) Sum() float64 { return s.his.Sum() }
+func (s *State) Sum() float64 { return s.timer.his.Sum() }
+
+// ExactSum returns the sum of the recorded durations.
+func (s *State) ExactSum() int64 { return s.timer.his.ExactSum() }
+
+// Min returns the shortest recorded duration.
+func (s *State) Min() int64 { return s.timer.his.Min() }
+
+// Max returns the longest recorded duration.
+func (s *State) Max() int64 { return s.timer.his.Max() }
 
 // Average returns an estimation of the sum and average.
-func (s *State) Average() (float64, float64) { return s.his.Average() }
+func (s *State) Average() (float64, float64) { return s.timer.his.Average() }
 
 // Variance returns an estimation of the sum, average and variance.
-func (s *State) Variance() (float64, float64, float64) { return s.his.Variance() }
+func (s *State) Variance() (float64, float64, float64) { return s.timer.his.Variance() }
@@ -0,0 +1,79 @@
+// +build nomon
+
+package mon
+
+import (
+	"github.com/zeebo/mon/internal/lfht"
+	"github.com/zeebo/mon/inthist"
+)
+
+// State keeps track of all of the information recorded for some name. With the
+// nomon tag, states are always nil and their methods return zero values.
+type State struct{}
+
+// Kind returns what the state records.
+func (s *State) Kind() Kind { return KindTimer }
+
+// Counter returns the state as a Counter.
+func (s *State) Counter() *Counter { return nil }
+
+// Gauge returns the state as a Gauge.
+func (s *State) Gauge() *Gauge { return nil }
+
+// Meter returns the state as a Meter.
+func (s *State) Meter() *Meter { return nil }
+
+// Distribution returns the state as a Distribution.
+func (s *State) Distribution() *Distribution { return nil }
+
+// Unit returns the unit of the values recorded by the state.
+func (s *State) Unit() Unit { return UnitNone }
+
+// Name returns the name the state was registered with.
+func (s *State) Name() string { return "" }
+
+// Labels returns the sorted labels the state was registered with.
+func (s *State) Labels() []Label { return nil }
+
+// Histogram returns an empty Histogram.
+func (s *State) Histogram() *inthist.Histogram { return new(inthist.Histogram) }
+
+// Errors returns an empty tree of error counters.
+func (s *State) Errors() *lfht.Table { return new(lfht.Table) }
+
+// Total returns the number of completed calls.
+func (s *State) Total() int64 { return 0 }
+
+// Current returns the number of calls that have started but not completed,
+// including calls that started before the last Collect.
+func (s *State) Current() int64 { return 0 }
+
+// Peak returns the largest value Current has had since the State was created.
+func (s *State) Peak() int64 { return 0 }
+
+// Quantile returns an estimation of the qth quantile in [0, 1].
+func (s *State) Quantile(q float64) int64 { return 0 }
+
+// Sum returns an estimation of the sum.
+func (s *State) Sum() float64 { return 0 }
+
+// ExactSum returns the sum of the recorded durations.
+func (s *State) ExactSum() int64 { return 0 }
+
+// Min returns the shortest recorded duration.
+func (s *State) Min() int64 { return 0 }
+
+// Max returns the longest recorded duration.
+func (s *State) Max() int64 { return 0 }
+
+// Average returns an estimation of the sum and average.
+func (s *State) Average() (float64, float64) { return 0, 0 }
+
+// Variance returns an estimation of the sum, average and variance.
+func (s *State) Variance() (float64, float64, float64) { return 0, 0, 0 }
+
+// Folded returns the number of lookups of new names folded into the state.
+func (s *State) Folded() int64 { return 0 }
+
+// SampleRate returns the sample rate the state was last timed with.
+func (s *State) SampleRate() int64 { return 1 }
@@ -1,3 +1,5 @@
+// +build !nomon
+
 package mon
 
 import (
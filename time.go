@@ -3,9 +3,10 @@
 package mon
 
 import (
-	"strings"
+	"context"
+	"fmt"
 	"sync/atomic"
-	_ "unsafe"
+	"unsafe"
 
 	"github.com/zeebo/this"
 )
@@ -13,70 +14,302 @@ import (
 //go:linkname nanotime runtime.nanotime
 func nanotime() (mono int64)
 
+// capturePanics is non-zero when Stop should check for panics.
+var capturePanics uint32
+
+// CapturePanics controls if Timer.Stop checks for a panic in flight. When it
+// does, a panic is recorded with an error kind like "panic(string)" and then
+// resumed. This only works when Stop is deferred directly, as in
+//
+//	defer mon.Start().Stop(&err)
+//
+// Because the panic is resumed from inside of Stop, the stack trace printed if
+// the panic crashes the program will start there.
+func CapturePanics(enabled bool) {
+	if enabled {
+		atomic.StoreUint32(&capturePanics, 1)
+	} else {
+		atomic.StoreUint32(&capturePanics, 0)
+	}
+}
+
+// thunkCache is the state a Thunk or NamedThunk last used, along with the
+// generation of the registry it was valid for.
+type thunkCache struct {
+	name   string
+	state  *State
+	gen    uint64
+	folded bool // the name was folded into an overflow state
+}
+
+// cachedState returns the cached state if it is still current. Using a cached
+// overflow state counts as another folded lookup, as it would without the
+// cache.
+func cachedState(cache *unsafe.Pointer) (*thunkCache, bool) {
+	c := (*thunkCache)(atomic.LoadPointer(cache))
+	if c == nil || c.gen != Default.generation() {
+		return c, false
+	}
+	if c.folded {
+		atomic.AddInt64(&c.state.folded, 1)
+	}
+	return c, true
+}
+
+// refreshState looks up the state for the name and caches it.
+func refreshState(cache *unsafe.Pointer, name string) *State {
+	// the generation must be read before the lookup so that a concurrent
+	// Collect causes the next call to refresh again.
+	gen := Default.generation()
+	state := GetState(name)
+	atomic.StorePointer(cache, unsafe.Pointer(&thunkCache{
+		name:   name,
+		state:  state,
+		gen:    gen,
+		folded: state.name != name,
+	}))
+	return state
+}
+
 // Thunk is a type that allows one to get the benefits of Time without having to
-// compute the caller every time it's called. Zero values are valid.
+// compute the caller or look up its State every time it's called. Zero values
+// are valid.
 type Thunk struct {
-	val atomic.Value
+	cache unsafe.Pointer // *thunkCache
 }
 
 // Time returns a Timer where the name is chosen the first time by the caller. Don't
 // use the same Thunk from different functions/methods.
 func (t *Thunk) Start() Timer {
-	name := t.val.Load()
-	if name == nil {
-		name = this.ThisN(1)
-		t.val.Store(name)
+	c, ok := cachedState(&t.cache)
+	if ok {
+		if timer, ok := startState(Default, c.state, false); ok {
+			return timer
+		}
+	}
+	if c != nil {
+		return startCached(&t.cache, c.name)
+	}
+	return startCached(&t.cache, this.ThisN(1))
+}
+
+// NamedThunk is like Thunk, but uses the provided name. Zero values besides the
+// name are valid, and the name must not be changed after first use.
+type NamedThunk struct {
+	Name  string
+	cache unsafe.Pointer // *thunkCache
+}
+
+// Start returns a Timer for the name.
+func (t *NamedThunk) Start() Timer {
+	if c, ok := cachedState(&t.cache); ok {
+		if timer, ok := startState(Default, c.state, false); ok {
+			return timer
+		}
+	}
+	return startCached(&t.cache, t.Name)
+}
+
+// startCached returns a Timer for the name, caching its state.
+func startCached(cache *unsafe.Pointer, name string) Timer {
+	for {
+		if timer, ok := startState(Default, refreshState(cache, name), false); ok {
+			return timer
+		}
 	}
-	return StartNamed(name.(string))
 }
 
 // Start returns a Timer using the calling function for the name.
 func Start() (t Timer) {
-	return StartNamed(this.ThisN(1))
+	skip, decided := Default.presample()
+	if skip {
+		return Timer{}
+	}
+	return Default.startNamed(this.ThisN(1), decided)
+}
+
+// StartNamed returns a Timer for the name in the Default registry.
+func StartNamed(name string) Timer { return Default.StartNamed(name) }
+
+// StartLabeled returns a Timer for the name and labels in the Default registry.
+func StartLabeled(name string, labels ...Label) Timer {
+	return Default.StartLabeled(name, labels...)
+}
+
+// StartContext returns a Timer using the calling function for the name. See
+// Registry.StartNamedContext.
+func StartContext(ctx context.Context) (context.Context, Timer) {
+	return Default.StartNamedContext(ctx, this.ThisN(1))
+}
+
+// StartNamedContext returns a Timer for the name in the Default registry. See
+// Registry.StartNamedContext.
+func StartNamedContext(ctx context.Context, name string) (context.Context, Timer) {
+	return Default.StartNamedContext(ctx, name)
+}
+
+// Start returns a Timer using the calling function for the name.
+func (r *Registry) Start() Timer {
+	skip, decided := r.presample()
+	if skip {
+		return Timer{}
+	}
+	return r.startNamed(this.ThisN(1), decided)
 }
 
 // StartNamed returns a Timer that records a duration when its Done method is called.
-func StartNamed(name string) Timer {
+func (r *Registry) StartNamed(name string) Timer {
+	skip, decided := r.presample()
+	if skip {
+		return Timer{}
+	}
+	return r.startNamed(name, decided)
+}
+
+// startNamed returns a Timer for the name, where decided is true if the call
+// was already sampled by presample.
+func (r *Registry) startNamed(name string, decided bool) Timer {
+	for {
+		if timer, ok := startState(r, r.GetState(name), decided); ok {
+			return timer
+		}
+	}
+}
+
+// StartLabeled returns a Timer that records a duration into the state for the
+// name and labels when its Done method is called.
+func (r *Registry) StartLabeled(name string, labels ...Label) Timer {
+	skip, decided := r.presample()
+	if skip {
+		return Timer{}
+	}
+	for {
+		if timer, ok := startState(r, r.GetLabeledState(name, labels...), decided); ok {
+			return timer
+		}
+	}
+}
+
+// startState returns a Timer that records a duration into the state, or false
+// if the state has been evicted. If the call is not sampled, the Timer does
+// nothing. If decided is true, the call was already sampled by presample.
+func startState(r *Registry, state *State, decided bool) (Timer, bool) {
+	n := state.sampleRate(r)
+	if !decided && n > 1 && fastrandn(uint32(n)) != 0 {
+		return Timer{}, true
+	}
+	if !state.start() {
+		return Timer{}, false
+	}
+	var ext *timerExt
+	if t := state.slowThreshold(); t != nil && t.Stack {
+		ext = &timerExt{stack: captureStack()}
+	}
 	return Timer{
 		now:   nanotime(),
-		state: GetState(name),
+		n:     n,
+		state: state,
+		ext:   ext,
+	}, true
+}
+
+// StartContext returns a Timer using the calling function for the name. See
+// StartNamedContext.
+func (r *Registry) StartContext(ctx context.Context) (context.Context, Timer) {
+	return r.StartNamedContext(ctx, this.ThisN(1))
+}
+
+// StartNamedContext returns a Timer that records a duration when its Stop method
+// is called. If the context came from a call to StartContext or StartNamedContext,
+// the duration is also recorded for the edge from that call to this one. The
+// returned context should be passed to any calls made before the Timer is stopped.
+func (r *Registry) StartNamedContext(ctx context.Context, name string) (context.Context, Timer) {
+	timer := r.StartNamed(name)
+	if parent, ok := contextParent(ctx); ok && timer.state != nil {
+		for timer.edge = r.getEdge(parent, name); !timer.edge.start(); {
+			timer.edge = r.getEdge(parent, name)
+		}
 	}
+	return context.WithValue(ctx, parentKey{}, name), timer
 }
 
-// Timer keeps track of the state necessary to record timing info.
+// Timer keeps track of the state necessary to record timing info. A Timer
+// for a call that was not sampled has no state and does nothing.
 type Timer struct {
 	now   int64
+	n     int64
 	state *State
+	edge  *State    // edge from the parent call in the context
+	ext   *timerExt // only allocated when needed
+}
+
+// timerExt holds the parts of a Timer that most timers do not need.
+type timerExt struct {
+	stack []uintptr // stack from the start when slow calls want it
 }
 
 // Stop records the timing info.
 func (r Timer) Stop(err *error) {
-	kind := ""
-	if err != nil {
-		kind = getKind(*err)
+	if r.state == nil {
+		return
 	}
+	var p interface{}
+	if atomic.LoadUint32(&capturePanics) != 0 {
+		p = recover()
+	}
+	r.stop(err, "", p)
+}
 
-	r.state.done(nanotime()-r.now, kind)
+// StopExemplar is like Stop, but keeps the duration as an exemplar identified
+// by id, like a trace or request id. See inthist.Histogram.ObserveExemplar.
+func (r Timer) StopExemplar(err *error, id string) {
+	if r.state == nil {
+		return
+	}
+	var p interface{}
+	if atomic.LoadUint32(&capturePanics) != 0 {
+		p = recover()
+	}
+	r.stop(err, id, p)
 }
 
-// getKind returns a string that attemps to be representative of the error.
-func getKind(err error) string {
-	if n, ok := err.(interface{ Name() (string, bool) }); ok {
-		if name, ok := n.Name(); ok {
-			return name
-		}
+// stop records the timing info with the error, or with the panic p that was
+// recovered by the caller before resuming it. The callers must recover the
+// panic themselves because recover only works when called directly by the
+// deferred function.
+func (r Timer) stop(err *error, exemplar string, p interface{}) {
+	if p != nil {
+		r.record(nil, fmt.Sprintf("panic(%T)", p), exemplar)
+		panic(p)
 	}
 
+	var e error
 	if err != nil {
-		s := err.Error()
-		if i := strings.IndexByte(s, ':'); i > 0 {
-			return s[:i]
-		} else if strings.IndexByte(s, ' ') == -1 {
-			return s
-		} else {
-			return "error"
-		}
+		e = *err
 	}
 
-	return ""
+	r.record(e, getKind(e), exemplar)
+}
+
+// Discard ends the call without recording anything for it, as when it turns
+// out the call did not happen. A Timer must not be both discarded and stopped.
+func (r Timer) Discard() {
+	if r.state == nil {
+		return
+	}
+	r.state.finish()
+	if r.edge != nil {
+		r.edge.finish()
+	}
+}
+
+// record records the duration since the Timer started with the error kind,
+// reporting it if the call was slow.
+func (r Timer) record(err error, kind, exemplar string) {
+	v := nanotime() - r.now
+	r.state.done(v, r.n, kind, exemplar)
+	if r.edge != nil {
+		r.edge.done(v, r.n, kind, exemplar)
+	}
+	r.state.reportSlow(v, kind, err, r.ext)
 }
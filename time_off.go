@@ -2,8 +2,10 @@
 
 package mon
 
-// Times calls the callback with all of the histograms that have been captured.
-func Times(func(string, *State) bool) {}
+import "context"
+
+// CapturePanics controls if Timer.Stop checks for a panic in flight.
+func CapturePanics(enabled bool) {}
 
 // Thunk is a type that allows one to get the benefits of Time without having to
 // compute the caller every time it's called. Zero values are valid.
@@ -13,14 +15,58 @@ type Thunk struct{}
 // use the same Thunk from different functions/methods.
 func (t *Thunk) Start() Timer { return Timer{} }
 
+// NamedThunk is like Thunk, but uses the provided name.
+type NamedThunk struct {
+	Name string
+}
+
+// Start returns a Timer for the name.
+func (t *NamedThunk) Start() Timer { return Timer{} }
+
 // Time returns a Timer using the calling function for the name.
 func Start() Timer { return Timer{} }
 
 // TimeNamed returns a Timer that records a duration when its Done method is called.
 func StartNamed(name string) Timer { return Timer{} }
 
+// StartLabeled returns a Timer for the name and labels.
+func StartLabeled(name string, labels ...Label) Timer { return Timer{} }
+
+// StartContext returns a Timer using the calling function for the name.
+func StartContext(ctx context.Context) (context.Context, Timer) { return ctx, Timer{} }
+
+// StartNamedContext returns a Timer for the name.
+func StartNamedContext(ctx context.Context, name string) (context.Context, Timer) {
+	return ctx, Timer{}
+}
+
+// Start returns a Timer using the calling function for the name.
+func (r *Registry) Start() Timer { return Timer{} }
+
+// StartNamed returns a Timer that records a duration when its Done method is called.
+func (r *Registry) StartNamed(name string) Timer { return Timer{} }
+
+// StartLabeled returns a Timer for the name and labels.
+func (r *Registry) StartLabeled(name string, labels ...Label) Timer { return Timer{} }
+
+// StartContext returns a Timer using the calling function for the name.
+func (r *Registry) StartContext(ctx context.Context) (context.Context, Timer) {
+	return ctx, Timer{}
+}
+
+// StartNamedContext returns a Timer for the name.
+func (r *Registry) StartNamedContext(ctx context.Context, name string) (context.Context, Timer) {
+	return ctx, Timer{}
+}
+
 // Timer keeps track of the state necessary to record timing info.
 type Timer struct{}
 
 // Stop records the timing info.
 func (Timer) Stop(*error) {}
+
+// StopExemplar records the timing info with an exemplar.
+func (Timer) StopExemplar(*error, string) {}
+
+// Discard ends the call without recording anything for it.
+func (Timer) Discard() {}
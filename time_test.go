@@ -1,10 +1,15 @@
+// +build !nomon
+
 package mon
 
 import (
+	"context"
 	"errors"
 	"testing"
 
 	"github.com/zeebo/assert"
+	"github.com/zeebo/mon/internal/lfht"
+	"github.com/zeebo/mon/inthist"
 	"github.com/zeebo/this"
 )
 
@@ -47,6 +52,154 @@ func TestTime(t *testing.T) {
 			return true
 		})
 	})
+
+	t.Run("Current", func(t *testing.T) {
+		t1 := StartNamed("foo")
+		t2 := StartNamed("foo")
+		st := LookupState("foo")
+		assert.Equal(t, st.Current(), 2)
+		assert.Equal(t, st.Peak(), 2)
+
+		t1.Stop(nil)
+		assert.Equal(t, st.Current(), 1)
+		t2.Stop(nil)
+		assert.Equal(t, st.Current(), 0)
+		assert.Equal(t, st.Peak(), 2)
+
+		Collect(func(string, *State) bool { return true })
+		StartNamed("foo").Stop(nil)
+		assert.Equal(t, LookupState("foo").Peak(), 1)
+		Collect(func(string, *State) bool { return true })
+	})
+
+	t.Run("CurrentAcrossCollect", func(t *testing.T) {
+		var reg Registry
+		stuck := reg.StartNamed("stuck")
+		reg.Collect(func(string, *State) bool { return true })
+
+		reg.StartNamed("stuck").Stop(nil)
+		st := reg.LookupState("stuck")
+		assert.Equal(t, st.Current(), 1)
+		assert.Equal(t, st.Peak(), 2)
+
+		stuck.Stop(nil)
+		assert.Equal(t, st.Current(), 0)
+
+		// the shared counts are kept until nothing uses them.
+		for i := 0; i < 2; i++ {
+			reg.Collect(func(string, *State) bool { return true })
+		}
+		assert.Equal(t, countKeys((*lfht.Table)(reg.shared[0])), 0)
+	})
+
+	t.Run("Discard", func(t *testing.T) {
+		var reg Registry
+		ctx, parent := reg.StartNamedContext(context.Background(), "parent")
+		_, child := reg.StartNamedContext(ctx, "child")
+		child.Discard()
+		parent.Stop(nil)
+
+		assert.Equal(t, reg.LookupState("child").Total(), 0)
+		assert.Equal(t, reg.LookupState("child").Current(), 0)
+		assert.Equal(t, reg.LookupEdge("parent", "child").Current(), 0)
+		assert.Equal(t, reg.LookupState("parent").Total(), 1)
+	})
+
+	t.Run("Panic", func(t *testing.T) {
+		CapturePanics(true)
+		defer CapturePanics(false)
+		defer Collect(func(string, *State) bool { return true })
+
+		inner := func() {
+			defer StartNamed("inner").Stop(nil)
+			panic("boom")
+		}
+		outer := func() (err error) {
+			defer StartNamed("outer").StopExemplar(&err, "trace")
+			inner()
+			return nil
+		}
+		caught := func() (p interface{}) {
+			defer func() { p = recover() }()
+			_ = outer()
+			return nil
+		}
+
+		assert.Equal(t, caught(), "boom")
+		assert.Equal(t, caught(), "boom")
+
+		for _, name := range []string{"inner", "outer"} {
+			st := LookupState(name)
+			assert.Equal(t, st.Total(), 2)
+			assert.Equal(t, st.Current(), 0)
+			counter := (*int64)(st.Errors().Lookup("panic(string)"))
+			assert.NotNil(t, counter)
+			assert.Equal(t, *counter, 2)
+		}
+
+		// no panic and no error still records a success
+		func() { defer StartNamed("inner").Stop(nil) }()
+		assert.Equal(t, LookupState("inner").Total(), 3)
+	})
+
+	t.Run("Labeled", func(t *testing.T) {
+		StartLabeled("foo", Label{"b", "2"}, Label{"a", "1"}).Stop(nil)
+		StartLabeled("foo", Label{"a", "1"}, Label{"b", "2"}).Stop(nil)
+		StartLabeled("foo", Label{"a", "2"}).Stop(nil)
+
+		Collect(func(key string, st *State) bool {
+			assert.Equal(t, st.Name(), "foo")
+			switch key {
+			case `foo{a="1",b="2"}`:
+				assert.Equal(t, st.Total(), 2)
+				assert.DeepEqual(t, st.Labels(), []Label{{"a", "1"}, {"b", "2"}})
+			case `foo{a="2"}`:
+				assert.Equal(t, st.Total(), 1)
+				assert.DeepEqual(t, st.Labels(), []Label{{"a", "2"}})
+			default:
+				t.Fatal("invalid key:", key)
+			}
+			return true
+		})
+	})
+
+	t.Run("Thunk", func(t *testing.T) {
+		var thunk Thunk
+		named := NamedThunk{Name: "named"}
+
+		check := func() {
+			thunk.Start().Stop(nil)
+			named.Start().Stop(nil)
+
+			totals := make(map[string]int64)
+			Collect(func(name string, st *State) bool {
+				totals[name] = st.Total()
+				return true
+			})
+			assert.DeepEqual(t, totals, map[string]int64{
+				"named":     1,
+				this.This(): 1,
+			})
+		}
+
+		check()
+		check()
+	})
+
+	t.Run("Exemplar", func(t *testing.T) {
+		err := errors.New("oops")
+		StartNamed("foo").StopExemplar(&err, "trace")
+
+		st := LookupState("foo")
+		assert.Equal(t, st.Total(), 1)
+		assert.Equal(t, *(*int64)(st.Errors().Lookup("oops")), 1)
+
+		var exs []string
+		st.Histogram().Exemplars(func(ex inthist.Exemplar) { exs = append(exs, ex.ID) })
+		assert.DeepEqual(t, exs, []string{"trace"})
+
+		Collect(func(string, *State) bool { return true })
+	})
 }
 
 func BenchmarkNanotime(b *testing.B) {
@@ -101,6 +254,44 @@ func BenchmarkTime(b *testing.B) {
 		}
 	})
 
+	b.Run("NamedThunk", func(b *testing.B) {
+		b.ReportAllocs()
+		thunk := NamedThunk{Name: "bench"}
+
+		for i := 0; i < b.N; i++ {
+			func() {
+				timer := thunk.Start()
+				defer timer.Stop(nil)
+			}()
+		}
+	})
+
+	b.Run("Sampled", func(b *testing.B) {
+		var reg Registry
+		reg.SetSampleRate(100)
+		b.ReportAllocs()
+
+		for i := 0; i < b.N; i++ {
+			func() {
+				timer := reg.StartNamed("bench")
+				defer timer.Stop(nil)
+			}()
+		}
+	})
+
+	b.Run("Context", func(b *testing.B) {
+		ctx, parent := StartNamedContext(context.Background(), "parent")
+		defer parent.Stop(nil)
+		b.ReportAllocs()
+
+		for i := 0; i < b.N; i++ {
+			func() {
+				_, timer := StartNamedContext(ctx, "bench")
+				defer timer.Stop(nil)
+			}()
+		}
+	})
+
 	b.Run("WithError", func(b *testing.B) {
 		err := errors.New("some error: whatever")
 		b.ReportAllocs()
@@ -0,0 +1,57 @@
+package mon
+
+import (
+	"strconv"
+	"time"
+)
+
+// Unit describes what the values recorded by a State measure.
+type Unit uint8
+
+const (
+	UnitNone        Unit = iota // plain numbers
+	UnitNanoseconds             // durations in nanoseconds
+	UnitBytes                   // sizes in bytes
+	UnitRatio                   // fractions where 1 is 100%
+)
+
+// String returns a lower case name for the unit, or the empty string for UnitNone.
+func (u Unit) String() string {
+	switch u {
+	case UnitNanoseconds:
+		return "nanoseconds"
+	case UnitBytes:
+		return "bytes"
+	case UnitRatio:
+		return "ratio"
+	default:
+		return ""
+	}
+}
+
+// Format returns a human readable representation of the value in the unit.
+func (u Unit) Format(v float64) string {
+	switch u {
+	case UnitNanoseconds:
+		return time.Duration(v).String()
+	case UnitBytes:
+		return formatBytes(v)
+	case UnitRatio:
+		return strconv.FormatFloat(v*100, 'g', 4, 64) + "%"
+	default:
+		return strconv.FormatFloat(v, 'g', 6, 64)
+	}
+}
+
+// formatBytes formats the value using binary prefixes.
+func formatBytes(v float64) string {
+	const prefixes = "KMGTPE"
+	if v < 1024 && v > -1024 {
+		return strconv.FormatFloat(v, 'f', -1, 64) + "B"
+	}
+	i := -1
+	for ; (v >= 1024 || v <= -1024) && i < len(prefixes)-1; i++ {
+		v /= 1024
+	}
+	return strconv.FormatFloat(v, 'f', 2, 64) + prefixes[i:i+1] + "iB"
+}
@@ -0,0 +1,182 @@
+// +build !nomon
+
+package mon
+
+import (
+	"sync/atomic"
+	"time"
+	"unsafe"
+
+	"github.com/zeebo/mon/internal/lfht"
+	"github.com/zeebo/mon/inthist"
+)
+
+const (
+	windowSlotNanos = int64(time.Minute)
+	windowSlots     = 16 // 15 minutes plus the current partial minute
+)
+
+// windowNow returns the time windows are read at. Tests replace it to read the
+// windows as if the current minute were over.
+var windowNow = nanotime
+
+// trackWindows is non-zero when timers should record into rolling windows.
+var trackWindows uint32
+
+// TrackWindows controls if timers keep rolling windows of their most recent
+// observations, up to 15 minutes, in addition to the cumulative histogram.
+// Unlike the cumulative histogram, the windows are shared by the states for a
+// name across Collect. See State.Window.
+func TrackWindows(enabled bool) {
+	if enabled {
+		atomic.StoreUint32(&trackWindows, 1)
+	} else {
+		atomic.StoreUint32(&trackWindows, 0)
+	}
+}
+
+// windowSlot is a histogram of the observations during one minute, along
+// with the counts of their error kinds.
+type windowSlot struct {
+	minute int64
+	his    inthist.Histogram
+	kinds  int64 // approximate number of error kinds
+	errors lfht.Table
+}
+
+// windows is a ring of the most recent minutes of observations for a name.
+type windows struct {
+	name  string
+	slots [windowSlots]unsafe.Pointer // *windowSlot
+}
+
+// expired reports if every slot is too old to be in a window at the time.
+func (w *windows) expired(now int64) bool {
+	minute := now / windowSlotNanos
+	for i := range w.slots {
+		slot := (*windowSlot)(atomic.LoadPointer(&w.slots[i]))
+		if slot != nil && slot.minute > minute-windowSlots {
+			return false
+		}
+	}
+	return true
+}
+
+// slot returns the slot for the time, replacing any slot for an older minute.
+func (w *windows) slot(now int64) *windowSlot {
+	minute := now / windowSlotNanos
+	addr := &w.slots[minute%windowSlots]
+
+	slot := (*windowSlot)(atomic.LoadPointer(addr))
+	for slot == nil || slot.minute < minute {
+		next := &windowSlot{minute: minute}
+		if atomic.CompareAndSwapPointer(addr, unsafe.Pointer(slot), unsafe.Pointer(next)) {
+			return next
+		}
+		slot = (*windowSlot)(atomic.LoadPointer(addr))
+	}
+	return slot
+}
+
+// observe records the value n times with the error kind, if any, into the slot
+// for the time, keeping about limit error kinds if it is positive.
+func (w *windows) observe(now, v, n int64, kind string, limit int64) {
+	slot := w.slot(now)
+	slot.his.ObserveN(v, n)
+	if kind != "" {
+		countKind(&slot.errors, &slot.kinds, limit, kind, n)
+	}
+}
+
+// window returns a histogram merging the slots in the last whole minutes before
+// the current partial minute.
+func (w *windows) window(now, minutes int64) *inthist.Histogram {
+	minute := now / windowSlotNanos
+	his := new(inthist.Histogram)
+	for i := range w.slots {
+		slot := (*windowSlot)(atomic.LoadPointer(&w.slots[i]))
+		if slot != nil && slot.minute >= minute-minutes && slot.minute < minute {
+			his.Merge(&slot.his)
+		}
+	}
+	return his
+}
+
+// errors calls the callback with the count of every error kind in the slots in
+// the last whole minutes before the current partial minute.
+func (w *windows) errors(now, minutes int64, cb func(kind string, count int64)) {
+	minute := now / windowSlotNanos
+	for i := range w.slots {
+		slot := (*windowSlot)(atomic.LoadPointer(&w.slots[i]))
+		if slot != nil && slot.minute >= minute-minutes && slot.minute < minute {
+			for iter := slot.errors.Iterator(); iter.Next(); {
+				cb(iter.Key(), atomic.LoadInt64((*int64)(iter.Value())))
+			}
+		}
+	}
+}
+
+// loadWindows returns the rolling windows for the state, allocating them if
+// necessary. They are shared with the states for the key in other generations.
+func (s *State) loadWindows() *windows {
+	sh := s.loadShared()
+	w := (*windows)(atomic.LoadPointer(&sh.windows))
+	if w == nil {
+		w = &windows{name: s.name}
+		if !atomic.CompareAndSwapPointer(&sh.windows, nil, unsafe.Pointer(w)) {
+			w = (*windows)(atomic.LoadPointer(&sh.windows))
+		}
+	}
+	return w
+}
+
+// recordedWindows returns the rolling windows for the state, or nil if none
+// have been recorded.
+func (s *State) recordedWindows() *windows {
+	if sh := (*shared)(atomic.LoadPointer(&s.shared)); sh != nil {
+		return (*windows)(atomic.LoadPointer(&sh.windows))
+	}
+	return nil
+}
+
+// observeWindow records the value n times with the error kind into the rolling
+// windows for the state.
+func (s *State) observeWindow(v, n int64, kind string) {
+	s.loadWindows().observe(nanotime(), v, n, kind, s.errorKindLimit())
+}
+
+// Window returns a histogram of the durations observed in the whole minutes
+// before the current one covering d, which is rounded up to whole minutes and
+// capped at 15. Durations observed in the current minute are left out until it
+// is over, so a window for a whole number of minutes spans exactly that long.
+// It returns nil if no windows have been recorded, which requires TrackWindows
+// to be enabled.
+func (s *State) Window(d time.Duration) *inthist.Histogram {
+	w := s.recordedWindows()
+	if w == nil {
+		return nil
+	}
+	return w.window(windowNow(), windowMinutes(d))
+}
+
+// WindowErrors returns the counts of the error kinds observed in the same span
+// as Window. It returns nil if no windows have been recorded.
+func (s *State) WindowErrors(d time.Duration) map[string]int64 {
+	w := s.recordedWindows()
+	if w == nil {
+		return nil
+	}
+	counts := make(map[string]int64)
+	w.errors(windowNow(), windowMinutes(d), func(kind string, count int64) { counts[kind] += count })
+	return counts
+}
+
+// windowMinutes returns the number of whole minutes before the current one
+// that a window of about d covers.
+func windowMinutes(d time.Duration) int64 {
+	minutes := (int64(d) + windowSlotNanos - 1) / windowSlotNanos
+	if minutes > windowSlots-1 {
+		minutes = windowSlots - 1
+	}
+	return minutes
+}
@@ -0,0 +1,18 @@
+// +build nomon
+
+package mon
+
+import (
+	"time"
+
+	"github.com/zeebo/mon/inthist"
+)
+
+// TrackWindows controls if states keep rolling windows of their observations.
+func TrackWindows(enabled bool) {}
+
+// Window returns nil because windows are never tracked.
+func (s *State) Window(d time.Duration) *inthist.Histogram { return nil }
+
+// WindowErrors returns nil because windows are never tracked.
+func (s *State) WindowErrors(d time.Duration) map[string]int64 { return nil }
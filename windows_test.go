@@ -0,0 +1,130 @@
+// +build !nomon
+
+package mon
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/zeebo/assert"
+)
+
+// readNextMinute makes windows read as if the current minute were over, and
+// returns a function that undoes it.
+func readNextMinute() func() {
+	windowNow = func() int64 { return nanotime() + windowSlotNanos }
+	return func() { windowNow = nanotime }
+}
+
+func TestWindows(t *testing.T) {
+	t.Run("Ring", func(t *testing.T) {
+		var w windows
+		minute := int64(time.Minute)
+		start := 1000 * minute
+
+		// one observation per minute for 20 minutes
+		for i := int64(0); i < 20; i++ {
+			kind := ""
+			if i%2 == 0 {
+				kind = "even"
+			}
+			w.observe(start+i*minute, i, 1, kind, 0)
+		}
+		now := start + 19*minute
+
+		// the current minute is left out
+		assert.Equal(t, w.window(now, 0).Total(), 0)
+		assert.Equal(t, w.window(now, 1).Total(), 1)
+		assert.Equal(t, w.window(now, 5).Total(), 5)
+		assert.Equal(t, w.window(now, 15).Total(), 15)
+		assert.Equal(t, w.window(now, 1).Quantile(0.5), 18)
+
+		evens := int64(0)
+		w.errors(now, 5, func(kind string, count int64) { evens += count })
+		assert.Equal(t, evens, 3)
+
+		// an hour later everything has expired
+		assert.Equal(t, w.window(now+60*minute, 15).Total(), 0)
+	})
+
+	t.Run("State", func(t *testing.T) {
+		defer Collect(func(string, *State) bool { return true })
+
+		StartNamed("windowed").Stop(nil)
+		assert.Nil(t, LookupState("windowed").Window(time.Minute))
+
+		TrackWindows(true)
+		defer TrackWindows(false)
+		defer readNextMinute()()
+
+		StartNamed("windowed").Stop(nil)
+		err := errors.New("problem")
+		StartNamed("windowed").Stop(&err)
+		st := LookupState("windowed")
+		assert.Equal(t, st.Total(), 3)
+		assert.Equal(t, st.Window(time.Minute).Total(), 2)
+		assert.Equal(t, st.Window(time.Hour).Total(), 2)
+		assert.DeepEqual(t, st.WindowErrors(time.Minute), map[string]int64{"problem": 1})
+	})
+
+	t.Run("Collect", func(t *testing.T) {
+		TrackWindows(true)
+		defer TrackWindows(false)
+		defer readNextMinute()()
+
+		var reg Registry
+		reg.StartNamed("windowed").Stop(nil)
+		ctx, parent := reg.StartNamedContext(context.Background(), "parent")
+		_, child := reg.StartNamedContext(ctx, "child")
+		child.Stop(nil)
+		parent.Stop(nil)
+
+		for i := 0; i < 2; i++ {
+			reg.Collect(func(string, *State) bool { return true })
+		}
+		assert.Nil(t, reg.LookupState("windowed"))
+
+		reg.StartNamed("windowed").Stop(nil)
+		ctx, parent = reg.StartNamedContext(context.Background(), "parent")
+		_, child = reg.StartNamedContext(ctx, "child")
+		child.Stop(nil)
+		parent.Stop(nil)
+
+		st := reg.LookupState("windowed")
+		assert.Equal(t, st.Total(), 1)
+		assert.Equal(t, st.Window(time.Minute).Total(), 2)
+		assert.Equal(t, reg.LookupEdge("parent", "child").Window(time.Minute).Total(), 2)
+	})
+
+	t.Run("DuringCollect", func(t *testing.T) {
+		TrackWindows(true)
+		defer TrackWindows(false)
+		defer readNextMinute()()
+
+		var reg Registry
+		for i := 0; i < 10; i++ {
+			reg.StartNamed("windowed").Stop(nil)
+		}
+		reg.Collect(func(string, *State) bool {
+			reg.StartNamed("windowed").Stop(nil)
+			return true
+		})
+
+		st := reg.LookupState("windowed")
+		assert.Equal(t, st.Total(), 1)
+		assert.Equal(t, st.Window(time.Minute).Total(), 11)
+
+		reg.Collect(func(string, *State) bool { return true })
+		reg.StartNamed("windowed").Stop(nil)
+		assert.Equal(t, reg.LookupState("windowed").Window(time.Minute).Total(), 12)
+	})
+
+	t.Run("Expired", func(t *testing.T) {
+		var w windows
+		w.observe(0, 1, 1, "", 0)
+		assert.That(t, !w.expired(windowSlotNanos))
+		assert.That(t, w.expired(windowSlots*windowSlotNanos))
+	})
+}